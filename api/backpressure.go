@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultRetryAfterSeconds = 1
+
+// errProducerBusy is returned when the message can't be enqueued without blocking, the
+// caller should retry after a short delay.
+var errProducerBusy = errors.New("producer is busy")
+
+type backpressureConfig struct {
+	// MaxInFlight is the maximum number of concurrent publishes per cluster, zero is unlimited.
+	MaxInFlight       int `json:"maxInFlight,omitempty"`
+	RetryAfterSeconds int `json:"retryAfterSeconds,omitempty"`
+}
+
+// retryAfterSeconds returns the delay suggested to callers when the producer is busy.
+func retryAfterSeconds() int {
+	if Config.Backpressure.RetryAfterSeconds <= 0 {
+		return defaultRetryAfterSeconds
+	}
+
+	return Config.Backpressure.RetryAfterSeconds
+}
+
+// inFlightLimiter bounds the concurrent publishes per cluster.
+type inFlightLimiter struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+var inFlight = &inFlightLimiter{
+	slots: map[string]chan struct{}{},
+}
+
+// acquire takes a slot for the cluster without blocking, returning false when all are in use.
+func (l *inFlightLimiter) acquire(cluster string) bool {
+	max := Config.Backpressure.MaxInFlight
+	if max <= 0 {
+		return true
+	}
+
+	cluster = strings.ToLower(cluster)
+
+	l.mu.Lock()
+	slots, ok := l.slots[cluster]
+	if !ok {
+		slots = make(chan struct{}, max)
+		l.slots[cluster] = slots
+	}
+	l.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees a slot taken by acquire.
+func (l *inFlightLimiter) release(cluster string) {
+	if Config.Backpressure.MaxInFlight <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	slots := l.slots[strings.ToLower(cluster)]
+	l.mu.Unlock()
+
+	<-slots
+}
+
+// watchEvents handles the producer events that aren't delivery reports, since those are sent
+// to the delivery channel of each message.
+func watchEvents(cluster string, instance *kafka.Producer) {
+	for e := range instance.Events() {
+		switch ev := e.(type) {
+		case kafka.Error:
+			if ev.IsFatal() {
+				log.Error().Err(ev).Msgf("fatal error from cluster %s", cluster)
+			} else {
+				log.Warn().Err(ev).Msgf("error from cluster %s", cluster)
+			}
+
+			if ev.Code() == kafka.ErrAllBrokersDown {
+				clusterHealthChecker.set(cluster, ev)
+			}
+		default:
+			if Config.Debug {
+				log.Debug().Msgf("event from cluster %s: %+v", cluster, ev)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type busyProducer struct {
+	countingProducer
+}
+
+func (bp *busyProducer) Produce(options ProduceOptions) *Result {
+	return &Result{Message: "Producer queue is full.", Error: errProducerBusy}
+}
+
+func TestInFlightLimiter(t *testing.T) {
+	Config.Backpressure.MaxInFlight = 2
+	defer func() {
+		Config.Backpressure.MaxInFlight = 0
+	}()
+
+	l := &inFlightLimiter{slots: map[string]chan struct{}{}}
+
+	assert.True(t, l.acquire("kafka-cl01"))
+	assert.True(t, l.acquire("KAFKA-CL01"))
+	assert.False(t, l.acquire("kafka-cl01"))
+	assert.True(t, l.acquire("kafka-cl02"))
+
+	l.release("kafka-cl01")
+	assert.True(t, l.acquire("kafka-cl01"))
+}
+
+func TestPublishEventProducerBusy(t *testing.T) {
+	setup()
+	rh := router{kp: &busyProducer{}}
+
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(`{"cluster": "kafka-cl01", "topic": "topic", "data": {}}`))
+	w := httptest.NewRecorder()
+	rh.PublishEvent(w, req)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	cloudEventsContentType  = "application/cloudevents+json"
+	cloudEventsSpecVersion  = "1.0"
+	cloudEventsHTTPPrefix   = "ce-"
+	cloudEventsKafkaPrefix  = "ce_"
+	cloudEventsClusterExt   = "kafkacluster"
+	cloudEventsTopicExt     = "kafkatopic"
+	cloudEventsPartitionKey = "partitionkey"
+	kafkaContentTypeHeader  = "content-type"
+)
+
+// cloudEventsConfig resolves the cluster and topic of CloudEvents without the kafkacluster and
+// kafkatopic extension attributes.
+type cloudEventsConfig struct {
+	Cluster string `json:"cluster,omitempty"`
+	// Topics maps the event type to a topic.
+	Topics map[string]string `json:"topics,omitempty"`
+}
+
+// isCloudEvent returns true if the request is a CloudEvent in structured or binary mode.
+func isCloudEvent(r *http.Request) bool {
+	return isStructuredCloudEvent(r) || len(r.Header.Get(cloudEventsHTTPPrefix+"specversion")) > 0
+}
+
+func isStructuredCloudEvent(r *http.Request) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mt == cloudEventsContentType
+}
+
+// decodeCloudEvent reads the CloudEvent and maps it to an event using the Kafka protocol binding
+// of the same mode: a structured event is produced as the whole event with a content-type header,
+// a binary event as its data with ce_ headers.
+func decodeCloudEvent(w http.ResponseWriter, r *http.Request) (EventRequest, *apiError) {
+	if isStructuredCloudEvent(r) {
+		return decodeStructuredCloudEvent(w, r)
+	}
+
+	return decodeBinaryCloudEvent(w, r)
+}
+
+func decodeStructuredCloudEvent(w http.ResponseWriter, r *http.Request) (EventRequest, *apiError) {
+	var event map[string]interface{}
+
+	if re := decodeBody(w, r, &event); re != nil {
+		return EventRequest{}, re
+	}
+
+	attributes := map[string]string{}
+	for name, value := range event {
+		if s, ok := value.(string); ok && name != "data" && name != "data_base64" {
+			attributes[name] = s
+		}
+	}
+
+	if re := validateCloudEvent(attributes); re != nil {
+		return EventRequest{}, re
+	}
+
+	er := cloudEventRequest(attributes)
+	er.Data = event
+	er.Headers = map[string]string{kafkaContentTypeHeader: cloudEventsContentType + "; charset=UTF-8"}
+
+	return er, nil
+}
+
+func decodeBinaryCloudEvent(w http.ResponseWriter, r *http.Request) (EventRequest, *apiError) {
+	attributes := map[string]string{}
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if !strings.HasPrefix(name, cloudEventsHTTPPrefix) || len(values) == 0 {
+			continue
+		}
+
+		value, err := url.PathUnescape(values[0])
+		if err != nil {
+			value = values[0]
+		}
+
+		attributes[strings.TrimPrefix(name, cloudEventsHTTPPrefix)] = value
+	}
+
+	if re := validateCloudEvent(attributes); re != nil {
+		return EventRequest{}, re
+	}
+
+	// the data is produced as the message value, which must be a JSON object
+	contentType := r.Header.Get("Content-Type")
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt != "application/json" && !strings.HasSuffix(mt, "+json") {
+		return EventRequest{}, &apiError{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    codeUnsupportedMedia,
+			Message: "binary CloudEvents must have JSON data",
+		}
+	}
+
+	er := cloudEventRequest(attributes)
+
+	if re := decodeBody(w, r, &er.Data); re != nil {
+		return EventRequest{}, re
+	}
+
+	er.Headers = map[string]string{kafkaContentTypeHeader: contentType}
+	for name, value := range attributes {
+		er.Headers[cloudEventsKafkaPrefix+name] = value
+	}
+
+	return er, nil
+}
+
+// validateCloudEvent checks the required context attributes.
+func validateCloudEvent(attributes map[string]string) *apiError {
+	fields := []fieldError{}
+
+	if v, ok := attributes["specversion"]; !ok {
+		fields = append(fields, fieldError{"specversion", fieldCodeRequired, "specversion is required"})
+	} else if v != cloudEventsSpecVersion {
+		fields = append(fields, fieldError{"specversion", "unsupported", fmt.Sprintf("specversion must be %s", cloudEventsSpecVersion)})
+	}
+
+	for _, name := range []string{"id", "source", "type"} {
+		if len(attributes[name]) == 0 {
+			fields = append(fields, fieldError{name, fieldCodeRequired, fmt.Sprintf("%s is required", name)})
+		}
+	}
+
+	if v, ok := attributes["time"]; ok {
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			fields = append(fields, fieldError{"time", "invalid_format", "time must be an RFC 3339 timestamp"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return validationError(fields)
+	}
+
+	return nil
+}
+
+// cloudEventRequest resolves the cluster, topic and key from the extension attributes, falling
+// back to the cloudEvents config.
+func cloudEventRequest(attributes map[string]string) EventRequest {
+	er := EventRequest{
+		Cluster: attributes[cloudEventsClusterExt],
+		Topic:   attributes[cloudEventsTopicExt],
+	}
+
+	if len(er.Cluster) == 0 {
+		er.Cluster = Config.CloudEvents.Cluster
+	}
+
+	if len(er.Topic) == 0 {
+		er.Topic = Config.CloudEvents.Topics[attributes["type"]]
+	}
+
+	if key, ok := attributes[cloudEventsPartitionKey]; ok {
+		er.Key = key
+	}
+
+	return er
+}
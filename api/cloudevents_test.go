@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishStructuredCloudEvent(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	rh := router{kp: cp}
+
+	body := `{
+		"specversion": "1.0",
+		"id": "e-1",
+		"source": "/orders",
+		"type": "com.example.order.created",
+		"kafkacluster": "kafka-cl01",
+		"kafkatopic": "orders",
+		"partitionkey": "order-1",
+		"data": {"id": 1}
+	}`
+
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	w := httptest.NewRecorder()
+
+	rh.PublishEvent(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "kafka-cl01", cp.last.Cluster)
+	assert.Equal(t, "orders", cp.last.Topic)
+	assert.Equal(t, "order-1", cp.last.Key)
+	assert.Equal(t, "e-1", cp.last.Data["id"])
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, cp.last.Data["data"])
+	assert.Equal(t, "application/cloudevents+json; charset=UTF-8", cp.last.Headers["content-type"])
+}
+
+func TestPublishBinaryCloudEvent(t *testing.T) {
+	setup()
+	Config.CloudEvents = cloudEventsConfig{
+		Cluster: "kafka-cl01",
+		Topics:  map[string]string{"com.example.order.created": "orders"},
+	}
+	defer func() {
+		Config.CloudEvents = cloudEventsConfig{}
+	}()
+
+	cp := &countingProducer{}
+	rh := router{kp: cp}
+
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(`{"id": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("ce-specversion", "1.0")
+	req.Header.Set("ce-id", "e-1")
+	req.Header.Set("ce-source", "/orders%20service")
+	req.Header.Set("ce-type", "com.example.order.created")
+	w := httptest.NewRecorder()
+
+	rh.PublishEvent(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "kafka-cl01", cp.last.Cluster)
+	assert.Equal(t, "orders", cp.last.Topic)
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, cp.last.Data)
+	assert.Equal(t, map[string]string{
+		"content-type":   "application/json",
+		"ce_specversion": "1.0",
+		"ce_id":          "e-1",
+		"ce_source":      "/orders service",
+		"ce_type":        "com.example.order.created",
+	}, cp.last.Headers)
+}
+
+func TestPublishInvalidCloudEvent(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	rh := router{kp: cp}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    string
+		status  int
+		fields  []string
+	}{
+		{
+			name:    "missing attributes",
+			headers: map[string]string{"Content-Type": "application/cloudevents+json"},
+			body:    `{"specversion": "0.3", "data": {}}`,
+			status:  http.StatusBadRequest,
+			fields:  []string{"specversion", "id", "source", "type"},
+		},
+		{
+			name:    "invalid time",
+			headers: map[string]string{"Content-Type": "application/cloudevents+json"},
+			body:    `{"specversion": "1.0", "id": "1", "source": "/", "type": "t", "time": "yesterday", "kafkacluster": "kafka-cl01", "kafkatopic": "orders"}`,
+			status:  http.StatusBadRequest,
+			fields:  []string{"time"},
+		},
+		{
+			name:    "binary without JSON data",
+			headers: map[string]string{"Content-Type": "text/plain", "ce-specversion": "1.0", "ce-id": "1", "ce-source": "/", "ce-type": "t"},
+			body:    `hello`,
+			status:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:    "unresolved topic",
+			headers: map[string]string{"Content-Type": "application/json", "ce-specversion": "1.0", "ce-id": "1", "ce-source": "/", "ce-type": "t", "ce-kafkacluster": "kafka-cl01"},
+			body:    `{}`,
+			status:  http.StatusBadRequest,
+			fields:  []string{"topic"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/events", strings.NewReader(tt.body))
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			rh.PublishEvent(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+
+			var resp errorResponse
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+			fields := []string{}
+			for _, f := range resp.Fields {
+				fields = append(fields, f.Field)
+			}
+			assert.ElementsMatch(t, tt.fields, fields)
+		})
+	}
+
+	assert.Equal(t, 0, cp.count)
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,3 +19,27 @@ func TestSetAppConfigError(t *testing.T) {
 
 	assert.NotNil(t, err)
 }
+
+func TestCheckAppConfig(t *testing.T) {
+	assert.Empty(t, CheckAppConfig("../testdata/app-config.json"))
+	assert.Len(t, CheckAppConfig("fake"), 1)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app-config.json")
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"serverPort":39000,"kafkaBrokerGroup":["kafka-cl01"]}`), 0600))
+	errs := CheckAppConfig(file)
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), `unknown field "kafkaBrokerGroup"`)
+	}
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"serverPort":39000,"kafkaBrokerGroups":["kafka-cl01"],"logRedaction":["$["],"topicAdmin":{"namePattern":"("}}`), 0600))
+	assert.Len(t, CheckAppConfig(file), 2)
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"serverPort":39000,"kafkaBrokerGroups":["kafka-cl01"],"rateLimits":[{"cluster":"kafka-cl02","messagesBurst":-1}]}`), 0600))
+	errs = CheckAppConfig(file)
+	if assert.Len(t, errs, 2) {
+		assert.Contains(t, errs[0].Error(), "messagesBurst -1 must not be negative")
+		assert.Contains(t, errs[1].Error(), "cluster 'kafka-cl02' is not in kafkaBrokerGroups")
+	}
+}
@@ -1,38 +1,75 @@
 package api
 
-import (
-	"encoding/json"
-	"io/ioutil"
-	"os"
-)
-
 // Config is the configuration instance.
 var Config appConfig
 
 type appConfig struct {
-	Debug             bool     `json:"debug"`
-	ServerPort        int      `json:"serverPort"`
-	EnableAPIAuth     bool     `json:"enableApiAuth"`
-	EnableTLS         bool     `json:"enableTLS"`
-	TLSCert           string   `json:"tlsCert"`
-	TLSKey            string   `json:"tlsKey"`
-	UseKafkaCertAuth  bool     `json:"useKafkaCertAuth"`
-	KafkaBrokerGroups []string `json:"kafkaBrokerGroups"`
-	KafkaHealthTopic  *string  `json:"kafkaHealthTopic,omitempty"`
+	Debug      bool `json:"debug"`
+	ServerPort int  `json:"serverPort"`
+	// GRPCPort enables the gRPC API on its own port.
+	GRPCPort int `json:"grpcPort,omitempty"`
+	// ReadTimeoutSeconds and WriteTimeoutSeconds bound reading the request and writing the
+	// response, streams must complete within them. Both default to 15.
+	ReadTimeoutSeconds  int      `json:"readTimeoutSeconds,omitempty"`
+	WriteTimeoutSeconds int      `json:"writeTimeoutSeconds,omitempty"`
+	EnableAPIAuth       bool     `json:"enableApiAuth"`
+	EnableTLS           bool     `json:"enableTLS"`
+	TLSCert             string   `json:"tlsCert"`
+	TLSKey              string   `json:"tlsKey"`
+	UseKafkaCertAuth    bool     `json:"useKafkaCertAuth"`
+	KafkaBrokerGroups   []string `json:"kafkaBrokerGroups"`
+	KafkaHealthTopic    *string  `json:"kafkaHealthTopic,omitempty"`
+	// KafkaFailover maps a primary cluster to its ordered fallback clusters.
+	KafkaFailover              map[string][]string `json:"kafkaFailover,omitempty"`
+	HealthCheckIntervalSeconds int                 `json:"healthCheckIntervalSeconds,omitempty"`
+	Spool                      spoolConfig         `json:"spool"`
+	Idempotency                idempotencyConfig   `json:"idempotency"`
+	Transactions               transactionsConfig  `json:"transactions"`
+	Durability                 durabilityConfig    `json:"durability"`
+	RateLimits                 []rateLimitRule     `json:"rateLimits,omitempty"`
+	Backpressure               backpressureConfig  `json:"backpressure"`
+	MaxBodyBytes               int64               `json:"maxBodyBytes,omitempty"`
+	ExposeErrorDetails         bool                `json:"exposeErrorDetails,omitempty"`
+	// LogRedaction are JSONPath patterns of the request values replaced before logging.
+	LogRedaction []string `json:"logRedaction,omitempty"`
+	// ProducerConfig holds non-secret librdkafka producer properties per cluster.
+	ProducerConfig map[string]map[string]interface{} `json:"producerConfig,omitempty"`
+	// Transforms are the payload transformation steps applied per topic before producing.
+	Transforms map[string][]transformStepConfig `json:"transforms,omitempty"`
+	// KeyRules derive the key of a topic's messages from the data when it's omitted.
+	KeyRules    map[string]keyRuleConfig `json:"keyRules,omitempty"`
+	CloudEvents cloudEventsConfig        `json:"cloudEvents"`
+	RestProxy   restProxyConfig          `json:"restProxy"`
+	Streaming   streamingConfig          `json:"streaming"`
+	WebSocket   webSocketConfig          `json:"websocket"`
+	Tail        tailConfig               `json:"tail"`
+	// Introspection caches and restricts the cluster and topic metadata endpoints.
+	Introspection introspectionConfig `json:"introspection"`
+	TopicAdmin    topicAdminConfig    `json:"topicAdmin"`
 }
 
 // SetAppConfig deserializes a config.json (any name) file into the config struct to allow access to
-// configuration values.
+// configuration values. Unknown fields are rejected, so typos don't silently fall back to defaults.
 func SetAppConfig(file string) error {
-	if _, err := os.Stat(file); os.IsNotExist(err) {
+	c := Config
+
+	if err := decodeStrict(file, &c); err != nil {
 		return err
 	}
 
-	b, _ := ioutil.ReadFile(file)
+	Config = c
 
-	if err := json.Unmarshal(b, &Config); err != nil {
-		return err
+	return nil
+}
+
+// CheckAppConfig decodes an app-config.json without applying it, returning the unknown fields
+// and the settings Serve would refuse to start with.
+func CheckAppConfig(file string) []error {
+	var c appConfig
+
+	if err := decodeStrict(file, &c); err != nil {
+		return []error{err}
 	}
 
-	return nil
+	return checkAppConfig(&c)
 }
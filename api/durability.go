@@ -0,0 +1,105 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	durabilityNone   = "none"
+	durabilityLeader = "leader"
+	durabilityAll    = "all"
+)
+
+// durabilityAcks maps each durability class to the producer acks setting.
+var durabilityAcks = map[string]kafka.ConfigValue{
+	durabilityNone:   0,
+	durabilityLeader: 1,
+	durabilityAll:    "all",
+}
+
+type durabilityConfig struct {
+	Enabled bool `json:"enabled"`
+	// Topics restricts the durability classes allowed per topic, topics not listed allow every class.
+	Topics map[string][]string `json:"topics,omitempty"`
+}
+
+// durabilityClasses returns the classes a producer is created for per cluster.
+func durabilityClasses() []string {
+	if !Config.Durability.Enabled {
+		return nil
+	}
+
+	return []string{durabilityNone, durabilityLeader, durabilityAll}
+}
+
+// validateDurabilityConfig ensures only known durability classes are configured.
+func validateDurabilityConfig(c durabilityConfig) error {
+	for topic, classes := range c.Topics {
+		for _, class := range classes {
+			if _, ok := durabilityAcks[class]; !ok {
+				return fmt.Errorf("durability topic '%s' has an unknown class '%s'", topic, class)
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveDurability returns the durability class to use for the topic, or an error if it can't be
+// used. Topics restricting the classes default to the first allowed one, so omitting the durability
+// doesn't bypass the restriction.
+func resolveDurability(topic, durability string) (string, error) {
+	classes, restricted := Config.Durability.Topics[topic]
+
+	if len(durability) == 0 {
+		if !Config.Durability.Enabled || !restricted || len(classes) == 0 {
+			return "", nil
+		}
+
+		return strings.ToLower(classes[0]), nil
+	}
+
+	durability = strings.ToLower(durability)
+
+	if !Config.Durability.Enabled {
+		return "", validationError([]fieldError{{"durability", codeNotEnabled, "durability classes are not enabled"}})
+	}
+
+	if _, ok := durabilityAcks[durability]; !ok {
+		return "", validationError([]fieldError{{
+			"durability",
+			"invalid_value",
+			fmt.Sprintf("unknown durability '%s', must be one of %s, %s or %s", durability, durabilityNone, durabilityLeader, durabilityAll),
+		}})
+	}
+
+	if !restricted {
+		return durability, nil
+	}
+
+	for _, class := range classes {
+		if strings.EqualFold(class, durability) {
+			return durability, nil
+		}
+	}
+
+	return "", &apiError{
+		Status:  http.StatusForbidden,
+		Code:    codeForbidden,
+		Message: fmt.Sprintf("durability '%s' is not allowed for topic '%s', allowed: %s", durability, topic, strings.Join(classes, ",")),
+	}
+}
+
+// applyDurability sets the acks for the durability class. The idempotent producer
+// requires acks=all, so it's disabled for the weaker classes.
+func applyDurability(durability string, kcm kafka.ConfigMap) {
+	kcm["acks"] = durabilityAcks[durability]
+
+	if durability != durabilityAll {
+		kcm["enable.idempotence"] = false
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveDurability(t *testing.T) {
+	Config.Durability = durabilityConfig{
+		Enabled: true,
+		Topics: map[string][]string{
+			"payments": {durabilityAll},
+		},
+	}
+	defer func() {
+		Config.Durability = durabilityConfig{}
+	}()
+
+	tests := []struct {
+		topic      string
+		durability string
+		resolved   string
+		valid      bool
+	}{
+		{"payments", "", durabilityAll, true},
+		{"payments", "all", durabilityAll, true},
+		{"payments", "none", "", false},
+		{"telemetry", "", "", true},
+		{"telemetry", "none", durabilityNone, true},
+		{"telemetry", "LEADER", durabilityLeader, true},
+		{"telemetry", "some", "", false},
+	}
+
+	for _, test := range tests {
+		durability, err := resolveDurability(test.topic, test.durability)
+		assert.Equal(t, test.valid, err == nil, "%s %s", test.topic, test.durability)
+		assert.Equal(t, test.resolved, durability, "%s %s", test.topic, test.durability)
+	}
+}
+
+func TestGetDurableProducer(t *testing.T) {
+	ctx := context.WithValue(context.Background(), producerctxkey, []producerCTX{
+		{Cluster: "kafka-cl01"},
+		{Cluster: "kafka-cl01", Durability: durabilityNone},
+	})
+
+	_, err := getDurableProducer(ctx, "kafka-cl01", durabilityNone)
+	assert.Nil(t, err)
+
+	_, err = getDurableProducer(ctx, "kafka-cl01", durabilityLeader)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Error codes returned in the `code` field of an errorResponse. These are stable, clients may branch on them.
+const (
+	codeInvalidJSON         = "invalid_json"
+	codeUnknownField        = "unknown_field"
+	codeValidationFailed    = "validation_failed"
+	codeUnauthorized        = "unauthorized"
+	codeForbidden           = "forbidden"
+	codeClusterNotFound     = "cluster_not_found"
+	codeTopicNotFound       = "topic_not_found"
+	codeNotEnabled          = "not_enabled"
+	codeIdempotencyConflict = "idempotency_conflict"
+	codeTopicExists         = "topic_exists"
+	codeBodyTooLarge        = "body_too_large"
+	codeMessageTooLarge     = "message_too_large"
+	codeUnsupportedMedia    = "unsupported_media_type"
+	codeRateLimited         = "rate_limited"
+	codeProducerBusy        = "producer_busy"
+	codeClusterUnavailable  = "cluster_unavailable"
+	codeTimeout             = "timeout"
+	codeInternal            = "internal_error"
+)
+
+// apiError is an error with the HTTP status and code returned to the caller. Retriable
+// indicates the same request may succeed if sent again later.
+type apiError struct {
+	Status    int
+	Code      string
+	Message   string
+	Retriable bool
+	// RetryAfter is the Retry-After in seconds, retriable 503s default to retryAfterSeconds.
+	RetryAfter int
+	Fields     []fieldError
+	Err        error
+}
+
+func (e *apiError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", e.Message, e.Err.Error())
+	}
+
+	return e.Message
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+func newAPIError(status int, code, message string, retriable bool, err error) *apiError {
+	return &apiError{
+		Status:    status,
+		Code:      code,
+		Message:   message,
+		Retriable: retriable,
+		Err:       err,
+	}
+}
+
+func newClusterNotFoundError(cluster string) *apiError {
+	return &apiError{
+		Status:  http.StatusNotFound,
+		Code:    codeClusterNotFound,
+		Message: fmt.Sprintf("kafka producer with the name '%s' was not found", cluster),
+	}
+}
+
+func newTopicNotFoundError(topic string, err error) *apiError {
+	return &apiError{
+		Status:  http.StatusNotFound,
+		Code:    codeTopicNotFound,
+		Message: fmt.Sprintf("topic '%s' was not found", topic),
+		Err:     err,
+	}
+}
+
+// classifyError maps any error to an apiError, defaulting to an internal error.
+func classifyError(err error) *apiError {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return ae
+	}
+
+	switch {
+	case errors.Is(err, errProducerBusy):
+		return newAPIError(http.StatusServiceUnavailable, codeProducerBusy, "producer is busy", true, err)
+	case errors.Is(err, errSpoolFull):
+		return newAPIError(http.StatusServiceUnavailable, codeClusterUnavailable, "cluster is unavailable and the spool is full", true, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return newAPIError(http.StatusGatewayTimeout, codeTimeout, "timed out", true, err)
+	}
+
+	var ke kafka.Error
+	if errors.As(err, &ke) {
+		switch ke.Code() {
+		case kafka.ErrUnknownTopic, kafka.ErrUnknownTopicOrPart:
+			return newAPIError(http.StatusNotFound, codeTopicNotFound, "topic was not found", false, err)
+		case kafka.ErrTopicAuthorizationFailed:
+			return newAPIError(http.StatusForbidden, codeForbidden, "not authorized to access the topic", false, err)
+		case kafka.ErrTopicAlreadyExists:
+			return newAPIError(http.StatusConflict, codeTopicExists, "topic already exists", false, err)
+		case kafka.ErrInvalidPartitions, kafka.ErrInvalidReplicationFactor, kafka.ErrInvalidReplicaAssignment, kafka.ErrInvalidConfig, kafka.ErrPolicyViolation:
+			return newAPIError(http.StatusBadRequest, codeValidationFailed, "rejected by the cluster", false, err)
+		case kafka.ErrMsgSizeTooLarge:
+			return newAPIError(http.StatusRequestEntityTooLarge, codeMessageTooLarge, "message is too large", false, err)
+		case kafka.ErrQueueFull:
+			return newAPIError(http.StatusServiceUnavailable, codeProducerBusy, "producer is busy", true, err)
+		case kafka.ErrTimedOut, kafka.ErrMsgTimedOut, kafka.ErrRequestTimedOut, kafka.ErrTimedOutQueue:
+			return newAPIError(http.StatusGatewayTimeout, codeTimeout, "timed out waiting on the cluster", true, err)
+		}
+
+		if isRetriableBrokerError(err) {
+			return newAPIError(http.StatusServiceUnavailable, codeClusterUnavailable, "cluster is unavailable", true, err)
+		}
+	}
+
+	return newAPIError(http.StatusInternalServerError, codeInternal, "internal error", false, err)
+}
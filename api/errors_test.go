@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err       error
+		status    int
+		code      string
+		retriable bool
+	}{
+		{newClusterNotFoundError("kafka-cl99"), http.StatusNotFound, codeClusterNotFound, false},
+		{fmt.Errorf("%w: queue full", errProducerBusy), http.StatusServiceUnavailable, codeProducerBusy, true},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout, codeTimeout, true},
+		{kafka.NewError(kafka.ErrUnknownTopicOrPart, "unknown", false), http.StatusNotFound, codeTopicNotFound, false},
+		{kafka.NewError(kafka.ErrTopicAuthorizationFailed, "denied", false), http.StatusForbidden, codeForbidden, false},
+		{kafka.NewError(kafka.ErrMsgSizeTooLarge, "too large", false), http.StatusRequestEntityTooLarge, codeMessageTooLarge, false},
+		{kafka.NewError(kafka.ErrMsgTimedOut, "timed out", false), http.StatusGatewayTimeout, codeTimeout, true},
+		{kafka.NewError(kafka.ErrAllBrokersDown, "down", false), http.StatusServiceUnavailable, codeClusterUnavailable, true},
+		{errors.New("oops"), http.StatusInternalServerError, codeInternal, false},
+	}
+
+	for _, test := range tests {
+		ae := classifyError(test.err)
+
+		assert.Equal(t, test.status, ae.Status, test.err.Error())
+		assert.Equal(t, test.code, ae.Code, test.err.Error())
+		assert.Equal(t, test.retriable, ae.Retriable, test.err.Error())
+	}
+}
+
+func TestPublishEventUnauthorized(t *testing.T) {
+	setup()
+	Config.EnableAPIAuth = true
+	defer func() {
+		Config.EnableAPIAuth = false
+	}()
+
+	rh := router{kp: &countingProducer{}}
+
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(`{"cluster": "kafka-cl01", "topic": "topic", "data": {}}`))
+	req.Header.Set("X-API-TOKEN", "wrong")
+	w := httptest.NewRecorder()
+	rh.PublishEvent(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+}
+
+func TestWriteErrorResponseHidesDetails(t *testing.T) {
+	setup()
+
+	w := httptest.NewRecorder()
+	w.Header().Set(requestIDHeader, "request-1")
+
+	_, err := kafkaClusterLookup("kafka-cl99")
+	writeErrorResponse(w, &log.Logger, "", err)
+
+	var er errorResponse
+	assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&er))
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	assert.Equal(t, codeClusterNotFound, er.Code)
+	assert.Equal(t, "request-1", er.RequestID)
+	assert.Empty(t, er.Error)
+	assert.NotContains(t, er.Message, "kafka-cl01")
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/traviisd/kafka-producer-proxy/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	grpcAPITokenKey     = "x-api-token"
+	grpcHealthPrefix    = "/grpc.health.v1.Health/"
+	grpcProducerService = "kafkaproducerproxy.v1.Producer"
+)
+
+// grpcServer implements the Producer service on top of the same producers as the HTTP router.
+type grpcServer struct {
+	pb.UnimplementedProducerServer
+	rh  router
+	log *zerolog.Logger
+}
+
+// newGRPCServer returns the gRPC server with the Producer and standard health services.
+func newGRPCServer(kp kafkaProducer, log *zerolog.Logger) (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpcUnary),
+		grpc.StreamInterceptor(grpcStream),
+	}
+
+	if Config.EnableTLS {
+		creds, err := credentials.NewServerTLSFromFile(Config.TLSCert, Config.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s := grpc.NewServer(opts...)
+	pb.RegisterProducerServer(s, &grpcServer{rh: router{kp}, log: log})
+
+	hs := health.NewServer()
+	setGRPCServingStatus(hs, clusterHealthChecker)
+	clusterHealthChecker.Watch(func() { setGRPCServingStatus(hs, clusterHealthChecker) })
+	healthpb.RegisterHealthServer(s, hs)
+
+	return s, nil
+}
+
+// setGRPCServingStatus reports NOT_SERVING while any cluster is unhealthy, the same as the /health endpoint.
+func setGRPCServingStatus(hs *health.Server, hc *healthChecker) {
+	serving := healthpb.HealthCheckResponse_SERVING
+	if !hc.AllHealthy() {
+		serving = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	hs.SetServingStatus("", serving)
+	hs.SetServingStatus(grpcProducerService, serving)
+}
+
+// serveGRPC listens on the grpcPort until the server is stopped.
+func serveGRPC(s *grpc.Server, log *zerolog.Logger) {
+	address := fmt.Sprintf(":%v", Config.GRPCPort)
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal().Err(err).Msg("gRPC Listen Error")
+	}
+
+	log.Info().Msgf("gRPC listening on port %s", address)
+
+	if err := s.Serve(lis); err != nil {
+		log.Fatal().Err(err).Msg("gRPC Serve Error")
+	}
+}
+
+// Publish produces a single event.
+func (s *grpcServer) Publish(ctx context.Context, req *pb.PublishRequest) (*pb.PublishResponse, error) {
+	resp, err := s.publish(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return resp, nil
+}
+
+// PublishBatch produces the events in order, a failed event doesn't stop the batch.
+func (s *grpcServer) PublishBatch(ctx context.Context, req *pb.PublishBatchRequest) (*pb.PublishBatchResponse, error) {
+	resp := &pb.PublishBatchResponse{}
+
+	for _, event := range req.Events {
+		resp.Results = append(resp.Results, s.publishResult(ctx, event))
+	}
+
+	return resp, nil
+}
+
+// PublishStream produces the events as they are received.
+func (s *grpcServer) PublishStream(stream pb.Producer_PublishStreamServer) error {
+	resp := &pb.PublishBatchResponse{}
+
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		resp.Results = append(resp.Results, s.publishResult(stream.Context(), event))
+	}
+}
+
+func (s *grpcServer) publishResult(ctx context.Context, req *pb.PublishRequest) *pb.PublishResult {
+	resp, err := s.publish(ctx, req)
+	if err != nil {
+		ae := classifyError(err)
+		return &pb.PublishResult{Error: &pb.PublishError{Code: ae.Code, Message: ae.Message, Retriable: ae.Retriable}}
+	}
+
+	return &pb.PublishResult{Response: resp}
+}
+
+func (s *grpcServer) publish(ctx context.Context, req *pb.PublishRequest) (*pb.PublishResponse, error) {
+	er := EventRequest{
+		Cluster:    req.Cluster,
+		Topic:      req.Topic,
+		Data:       req.Data.AsMap(),
+		Durability: req.Durability,
+	}
+
+	if req.Key != nil {
+		er.Key = req.Key.AsInterface()
+	}
+
+	if re := validateEventRequest(er); re != nil {
+		return nil, re
+	}
+
+	apiToken, remoteAddr := grpcCaller(ctx)
+	log := s.log.With().Str("ip", remoteAddr).Logger()
+
+	result, err := s.rh.produceEvent(ctx, &log, principalOf(apiToken, remoteAddr), er)
+	if err != nil {
+		ae := classifyError(err)
+		if ae.Status >= http.StatusInternalServerError {
+			log.Error().Err(err).Msg("gRPC publish failed")
+		} else {
+			log.Warn().Err(err).Msg("gRPC publish failed")
+		}
+
+		return nil, err
+	}
+
+	return &pb.PublishResponse{
+		Message:   result.Message,
+		Cluster:   result.Cluster,
+		Partition: result.Partition,
+		Offset:    result.Offset,
+		Spooled:   result.Spooled,
+	}, nil
+}
+
+// grpcCaller returns the API token and remote address of the call.
+func grpcCaller(ctx context.Context) (string, string) {
+	apiToken := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcAPITokenKey); len(values) > 0 {
+			apiToken = values[0]
+		}
+	}
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+
+	return apiToken, remoteAddr
+}
+
+func grpcAuthorize(ctx context.Context, method string) error {
+	if strings.HasPrefix(method, grpcHealthPrefix) {
+		return nil
+	}
+
+	if err := checkAPIToken(grpcCaller(ctx)); err != nil {
+		return grpcError(err)
+	}
+
+	return nil
+}
+
+// grpcUnary authorizes the call and adds the producers to the context, like the kafkaMiddleware.
+func grpcUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := grpcAuthorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(context.WithValue(ctx, producerctxkey, producerCTXs), req)
+}
+
+func grpcStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := grpcAuthorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, &grpcServerStream{ss, context.WithValue(ss.Context(), producerctxkey, producerCTXs)})
+}
+
+// grpcServerStream overrides the context of the stream.
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcCodes maps the HTTP status of an apiError to the gRPC code.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// grpcError maps the error to a status, the details are only included if exposeErrorDetails is set.
+func grpcError(err error) error {
+	ae := classifyError(err)
+
+	code, ok := grpcCodes[ae.Status]
+	if !ok {
+		code = codes.Internal
+	}
+
+	message := fmt.Sprintf("%s: %s", ae.Code, ae.Message)
+	for _, f := range ae.Fields {
+		message = fmt.Sprintf("%s; %s: %s", message, f.Field, f.Message)
+	}
+
+	if Config.ExposeErrorDetails && ae.Err != nil {
+		message = fmt.Sprintf("%s: %s", message, ae.Err.Error())
+	}
+
+	return status.Error(code, message)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/traviisd/kafka-producer-proxy/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func dialGRPC(t *testing.T, kp kafkaProducer) *grpc.ClientConn {
+	log := zerolog.Nop()
+
+	// the health status follows the clusters, which other tests may have left unhealthy
+	clusterHealthChecker = newHealthChecker()
+
+	s, err := newGRPCServer(kp, &log)
+	assert.Nil(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestGRPCPublish(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	client := pb.NewProducerClient(dialGRPC(t, cp))
+
+	data, _ := structpb.NewStruct(map[string]interface{}{"id": 1})
+
+	resp, err := client.Publish(context.Background(), &pb.PublishRequest{
+		Cluster: "kafka-cl01",
+		Topic:   "orders",
+		Key:     structpb.NewStringValue("k1"),
+		Data:    data,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "kafka-cl01", resp.Cluster)
+	assert.Equal(t, "k1", cp.last.Key)
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, cp.last.Data)
+
+	_, err = client.Publish(context.Background(), &pb.PublishRequest{Cluster: "kafka-cl01", Topic: "orders!"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 1, cp.count)
+}
+
+func TestGRPCPublishBatchAndStream(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	client := pb.NewProducerClient(dialGRPC(t, cp))
+
+	events := []*pb.PublishRequest{
+		{Cluster: "kafka-cl01", Topic: "orders"},
+		{Cluster: "kafka-cl01"},
+		{Cluster: "kafka-cl01", Topic: "payments"},
+	}
+
+	resp, err := client.PublishBatch(context.Background(), &pb.PublishBatchRequest{Events: events})
+	assert.Nil(t, err)
+	assert.Len(t, resp.Results, 3)
+	assert.NotNil(t, resp.Results[0].Response)
+	assert.Equal(t, codeValidationFailed, resp.Results[1].Error.Code)
+	assert.NotNil(t, resp.Results[2].Response)
+
+	stream, err := client.PublishStream(context.Background())
+	assert.Nil(t, err)
+
+	for _, e := range events {
+		assert.Nil(t, stream.Send(e))
+	}
+
+	resp, err = stream.CloseAndRecv()
+	assert.Nil(t, err)
+	assert.Len(t, resp.Results, 3)
+	assert.Equal(t, codeValidationFailed, resp.Results[1].Error.Code)
+	assert.Equal(t, 4, cp.count)
+}
+
+func TestGRPCAuth(t *testing.T) {
+	setup()
+	Config.EnableAPIAuth = true
+	defer func() {
+		Config.EnableAPIAuth = false
+	}()
+
+	conn := dialGRPC(t, &countingProducer{})
+	client := pb.NewProducerClient(conn)
+	req := &pb.PublishRequest{Cluster: "kafka-cl01", Topic: "orders"}
+
+	_, err := client.Publish(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcAPITokenKey, Secrets.APIToken)
+	_, err = client.Publish(ctx, req)
+	assert.Nil(t, err)
+
+	// health checks don't require a token
+	hc, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: grpcProducerService})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, hc.Status)
+}
+
+func TestGRPCHealth(t *testing.T) {
+	setup()
+
+	client := healthpb.NewHealthClient(dialGRPC(t, &countingProducer{}))
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		hc, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: grpcProducerService})
+		assert.Nil(t, err)
+		return hc.Status
+	}
+
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check())
+
+	clusterHealthChecker.set("kafka-cl01", errors.New("all brokers down"))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check())
+
+	clusterHealthChecker.set("kafka-cl01", nil)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check())
+}
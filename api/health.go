@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultHealthCheckIntervalSeconds = 30
+
+// clusterHealth is the last known health state of a cluster.
+type clusterHealth struct {
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// healthChecker keeps track of the health state of each configured cluster.
+type healthChecker struct {
+	mu     sync.RWMutex
+	states map[string]clusterHealth
+	// topics are the topics of each cluster as of the last check, only known when the
+	// check fetches the metadata of all topics.
+	topics map[string]map[string]bool
+	// watchers are called after the health state of a cluster is set.
+	watchers []func()
+}
+
+// clusterHealthChecker is the health checker instance shared by the router and producer.
+var clusterHealthChecker = newHealthChecker()
+
+func newHealthChecker() *healthChecker {
+	return &healthChecker{
+		states: map[string]clusterHealth{},
+		topics: map[string]map[string]bool{},
+	}
+}
+
+// set records the result of a health check for the cluster.
+func (hc *healthChecker) set(cluster string, err error) {
+	state := clusterHealth{
+		Healthy:   err == nil,
+		CheckedAt: time.Now(),
+	}
+
+	if err != nil {
+		state.Error = err.Error()
+	}
+
+	hc.mu.Lock()
+	hc.states[cluster] = state
+	watchers := hc.watchers
+	hc.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn()
+	}
+}
+
+// Watch registers a function called every time the health state of a cluster is set.
+func (hc *healthChecker) Watch(fn func()) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.watchers = append(hc.watchers, fn)
+}
+
+// setTopics records the topics of the metadata returned by a health check of the cluster.
+func (hc *healthChecker) setTopics(cluster string, md *kafka.Metadata) {
+	topics := map[string]bool{}
+	for name := range md.Topics {
+		topics[name] = true
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.topics[cluster] = topics
+}
+
+// TopicKnown returns whether the topic existed on the last health check of the cluster,
+// checked is false if the topics of the cluster aren't known.
+func (hc *healthChecker) TopicKnown(cluster, topic string) (known bool, checked bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	topics, checked := hc.topics[cluster]
+
+	return topics[topic], checked
+}
+
+// IsHealthy returns false only if the last health check of the cluster failed,
+// clusters that have not been checked yet are assumed to be healthy.
+func (hc *healthChecker) IsHealthy(cluster string) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	state, ok := hc.states[cluster]
+
+	return !ok || state.Healthy
+}
+
+// AllHealthy returns false if the last health check of any configured cluster failed.
+func (hc *healthChecker) AllHealthy() bool {
+	for _, cluster := range Config.KafkaBrokerGroups {
+		if !hc.IsHealthy(cluster) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Run checks the health of every cluster on an interval.
+// This function is blocking so it is meant to be used by running `go Run(done)`
+func (hc *healthChecker) Run(done chan bool) {
+	interval := Config.HealthCheckIntervalSeconds
+	if interval <= 0 {
+		interval = defaultHealthCheckIntervalSeconds
+	}
+
+	ticker := time.NewTicker(time.Second * time.Duration(interval))
+	defer ticker.Stop()
+
+	ctx := context.WithValue(context.Background(), producerctxkey, producerCTXs)
+
+	for {
+		for _, cluster := range Config.KafkaBrokerGroups {
+			md, err := checkClusterHealth(ctx, cluster)
+			if err != nil {
+				log.Warn().Err(err).Msgf("cluster %s is unhealthy", cluster)
+			} else if Config.KafkaHealthTopic == nil {
+				hc.setTopics(cluster, md)
+			}
+
+			hc.set(cluster, err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// checkClusterHealth fetches the metadata of the health topic (or all topics) to
+// ensure the cluster is reachable.
+func checkClusterHealth(ctx context.Context, cluster string) (*kafka.Metadata, error) {
+	p, err := getProducer(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := kafka.NewAdminClientFromProducer(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get a single or all topics, timeout of 15 seconds
+	return ac.GetMetadata(Config.KafkaHealthTopic, (Config.KafkaHealthTopic == nil), 15000)
+}
+
+// State returns the last known health state of the cluster, false if it hasn't been checked yet.
+func (hc *healthChecker) State(cluster string) (clusterHealth, bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	state, ok := hc.states[cluster]
+
+	return state, ok
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthCheckerIsHealthy(t *testing.T) {
+	hc := newHealthChecker()
+
+	assert.True(t, hc.IsHealthy("kafka-cl01"))
+
+	hc.set("kafka-cl01", errors.New("all brokers down"))
+	assert.False(t, hc.IsHealthy("kafka-cl01"))
+
+	hc.set("kafka-cl01", nil)
+	assert.True(t, hc.IsHealthy("kafka-cl01"))
+}
+
+func TestHealthCheckerTopicKnown(t *testing.T) {
+	hc := newHealthChecker()
+
+	_, checked := hc.TopicKnown("kafka-cl01", "orders")
+	assert.False(t, checked)
+
+	hc.setTopics("kafka-cl01", &kafka.Metadata{Topics: map[string]kafka.TopicMetadata{"orders": {Topic: "orders"}}})
+
+	known, checked := hc.TopicKnown("kafka-cl01", "orders")
+	assert.True(t, known)
+	assert.True(t, checked)
+
+	known, _ = hc.TopicKnown("kafka-cl01", "payments")
+	assert.False(t, known)
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	defaultIdempotencyTTL     = 86400
+	defaultIdempotencyMaxKeys = 100000
+)
+
+// idempotencyKeys is nil unless idempotency keys are enabled.
+var idempotencyKeys *idempotencyCache
+
+type idempotencyConfig struct {
+	Enabled    bool `json:"enabled"`
+	TTLSeconds int  `json:"ttlSeconds,omitempty"`
+	MaxKeys    int  `json:"maxKeys,omitempty"`
+}
+
+// idempotencyEntry is the stored result of a request. done is closed once the result is known.
+type idempotencyEntry struct {
+	key     string
+	hash    [sha256.Size]byte
+	status  int
+	body    []byte
+	expires time.Time
+	done    chan struct{}
+}
+
+// idempotencyCache is a bounded, least recently used cache of request results with a TTL.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxKeys int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newIdempotencyCache(cfg idempotencyConfig) *idempotencyCache {
+	if cfg.TTLSeconds <= 0 {
+		cfg.TTLSeconds = defaultIdempotencyTTL
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = defaultIdempotencyMaxKeys
+	}
+
+	return &idempotencyCache{
+		ttl:     time.Second * time.Duration(cfg.TTLSeconds),
+		maxKeys: cfg.MaxKeys,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Begin looks up the key, returning the existing entry and true if found. Otherwise a pending
+// entry is added and returned, the caller must then pass it to Complete or Forget.
+func (c *idempotencyCache) Begin(key string, hash [sha256.Size]byte) (*idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*idempotencyEntry)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			return entry, true
+		}
+
+		c.remove(el)
+	}
+
+	entry := &idempotencyEntry{
+		key:     key,
+		hash:    hash,
+		expires: time.Now().Add(c.ttl),
+		done:    make(chan struct{}),
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxKeys {
+		c.remove(c.lru.Back())
+	}
+
+	return entry, false
+}
+
+// lookup returns the element of the entry, false if the entry was evicted, even if its key was
+// added again since.
+func (c *idempotencyCache) lookup(entry *idempotencyEntry) (*list.Element, bool) {
+	el, ok := c.entries[entry.key]
+	if !ok || el.Value.(*idempotencyEntry) != entry {
+		return nil, false
+	}
+
+	return el, true
+}
+
+// Complete stores the result of the entry returned by Begin. Nothing is stored if the entry was
+// evicted in the meantime, its waiters were already released.
+func (c *idempotencyCache) Complete(entry *idempotencyEntry, status int, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.lookup(entry); !ok {
+		return
+	}
+
+	entry.status = status
+	entry.body = body
+	close(entry.done)
+}
+
+// Forget removes the entry returned by Begin so the request may be retried, used when the request failed.
+func (c *idempotencyCache) Forget(entry *idempotencyEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.lookup(entry); ok {
+		c.remove(el)
+	}
+}
+
+func (c *idempotencyCache) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*idempotencyEntry)
+	delete(c.entries, entry.key)
+
+	// release anyone waiting on a request that never completed
+	select {
+	case <-entry.done:
+	default:
+		close(entry.done)
+	}
+}
+
+// Wait blocks until the result of the entry is known. The result is empty if the
+// original request failed and the key was forgotten.
+func (e *idempotencyEntry) Wait(ctx context.Context) (int, []byte, error) {
+	select {
+	case <-e.done:
+		return e.status, e.body, nil
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// idempotencyCacheKey scopes the idempotency key to the caller and cluster, so a caller can't
+// replay the stored response of another.
+func idempotencyCacheKey(principal, cluster, key string) string {
+	return strings.Join([]string{principal, strings.ToLower(cluster), key}, "|")
+}
+
+// requestHash returns a hash of the request, marshaling sorts map keys so the same
+// payload always has the same hash regardless of field order or whitespace.
+func requestHash(er EventRequest) [sha256.Size]byte {
+	// every field is listed since the headers aren't part of the request's JSON, e.g. the ce_id
+	// of a binary CloudEvent
+	b, _ := json.Marshal(struct {
+		Cluster    string                 `json:"cluster"`
+		Topic      string                 `json:"topic"`
+		Key        interface{}            `json:"key"`
+		Data       map[string]interface{} `json:"data"`
+		Durability string                 `json:"durability"`
+		Headers    map[string]string      `json:"headers"`
+	}{er.Cluster, er.Topic, er.Key, er.Data, er.Durability, er.Headers})
+
+	return sha256.Sum256(b)
+}
+
+// responseRecorder captures the response while writing it through.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.body = append(rr.body, b...)
+
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingProducer struct {
+	mu    sync.Mutex
+	count int
+	last  ProduceOptions
+}
+
+func (cp *countingProducer) Produce(options ProduceOptions) *Result {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.count++
+	cp.last = options
+
+	result := &Result{Message: options.Topic, Cluster: options.Cluster}
+	if msg, failed := newMessage(options.Topic, options.Key, options.Data); failed == nil {
+		result.KeySize, result.ValueSize = len(msg.Key), len(msg.Value)
+	}
+
+	return result
+}
+
+func (cp *countingProducer) ProduceTransaction(options TransactionOptions) *TransactionResult {
+	cp.count += len(options.Events)
+
+	return &TransactionResult{}
+}
+
+func TestIdempotencyCacheEviction(t *testing.T) {
+	c := newIdempotencyCache(idempotencyConfig{MaxKeys: 2})
+
+	begin := func(key string) bool {
+		_, existing := c.Begin(key, requestHash(EventRequest{Topic: key}))
+		return existing
+	}
+
+	assert.False(t, begin("a"))
+	assert.False(t, begin("b"))
+	assert.False(t, begin("c"))
+
+	// "a" was evicted
+	assert.False(t, begin("a"))
+	assert.True(t, begin("c"))
+}
+
+func TestIdempotencyCacheCompleteEvicted(t *testing.T) {
+	c := newIdempotencyCache(idempotencyConfig{MaxKeys: 1})
+	hash := requestHash(EventRequest{Topic: "a"})
+
+	evicted, _ := c.Begin("a", hash)
+	c.Begin("b", hash)
+
+	// "a" is added again while the request of the evicted entry is still running
+	entry, existing := c.Begin("a", hash)
+	assert.False(t, existing)
+
+	assert.NotPanics(t, func() { c.Complete(evicted, http.StatusOK, []byte("evicted")) })
+	assert.NotPanics(t, func() { c.Forget(evicted) })
+
+	c.Complete(entry, http.StatusOK, []byte("result"))
+
+	replayed, existing := c.Begin("a", hash)
+	assert.True(t, existing)
+
+	status, body, err := replayed.Wait(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "result", string(body))
+}
+
+func TestRequestHashHeaders(t *testing.T) {
+	er := EventRequest{Cluster: "kafka-cl01", Topic: "orders", Headers: map[string]string{"ce_id": "1"}}
+	other := er
+	other.Headers = map[string]string{"ce_id": "2"}
+
+	assert.NotEqual(t, requestHash(er), requestHash(other))
+}
+
+func TestPublishEventIdempotencyKey(t *testing.T) {
+	setup()
+	idempotencyKeys = newIdempotencyCache(idempotencyConfig{})
+	defer func() {
+		idempotencyKeys = nil
+	}()
+
+	cp := &countingProducer{}
+	rh := router{kp: cp}
+
+	publish := func(body string) *http.Response {
+		req := httptest.NewRequest("POST", "/events", strings.NewReader(body))
+		req.Header.Set(idempotencyKeyHeader, "key-1")
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+
+		rh.PublishEvent(w, req)
+
+		return w.Result()
+	}
+
+	resp := publish(`{"cluster": "kafka-cl01", "topic": "topic", "data": {"id": 1}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = publish(`{"topic": "topic", "cluster": "kafka-cl01", "data": {"id": 1}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get(idempotentReplayedHeader))
+	assert.Equal(t, 1, cp.count)
+
+	resp = publish(`{"cluster": "kafka-cl01", "topic": "topic", "data": {"id": 2}}`)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, 1, cp.count)
+
+	// the key of another caller isn't replayed
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(`{"cluster": "kafka-cl01", "topic": "topic", "data": {"id": 1}}`))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	req.RemoteAddr = "192.0.2.2:1234"
+	w := httptest.NewRecorder()
+	rh.PublishEvent(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, 2, cp.count)
+}
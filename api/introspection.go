@@ -0,0 +1,469 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+)
+
+const (
+	defaultIntrospectionCacheSeconds = 30
+	introspectionTimeoutMs           = 10000
+
+	connectionConnected    = "connected"
+	connectionDisconnected = "disconnected"
+)
+
+// keyTopicConfigs are the topic configs returned by GET /clusters/{cluster}/topics/{topic}.
+var keyTopicConfigs = []string{
+	"cleanup.policy",
+	"compression.type",
+	"max.message.bytes",
+	"message.timestamp.type",
+	"min.insync.replicas",
+	"retention.bytes",
+	"retention.ms",
+	"segment.bytes",
+}
+
+// introspectionConfig controls the cluster and topic metadata endpoints.
+type introspectionConfig struct {
+	// CacheSeconds is how long the metadata is cached per cluster and topic. Defaults to 30.
+	CacheSeconds int `json:"cacheSeconds,omitempty"`
+	// Access restricts the clusters and topics each caller can see, everything when empty.
+	Access []introspectionAccess `json:"access,omitempty"`
+}
+
+// introspectionAccess grants the principals it matches the topics of the cluster, an empty or "*"
+// principal or cluster matches everything. Topics are glob patterns, all topics when empty.
+type introspectionAccess struct {
+	Principal string   `json:"principal,omitempty"`
+	Cluster   string   `json:"cluster,omitempty"`
+	Topics    []string `json:"topics,omitempty"`
+}
+
+func (a introspectionAccess) matches(principal, cluster string) bool {
+	return rateLimitRule{Principal: a.Principal, Cluster: a.Cluster}.matches(principal, cluster, "")
+}
+
+func (a introspectionAccess) allows(topic string) bool {
+	if len(a.Topics) == 0 {
+		return true
+	}
+
+	for _, pattern := range a.Topics {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clusterVisible returns true if any access rule grants the principal the cluster.
+func clusterVisible(principal, cluster string) bool {
+	if len(Config.Introspection.Access) == 0 {
+		return true
+	}
+
+	for _, a := range Config.Introspection.Access {
+		if a.matches(principal, cluster) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// topicVisible returns true if any access rule grants the principal the topic of the cluster.
+func topicVisible(principal, cluster, topic string) bool {
+	if len(Config.Introspection.Access) == 0 {
+		return true
+	}
+
+	for _, a := range Config.Introspection.Access {
+		if a.matches(principal, cluster) && a.allows(topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func introspectionCacheTTL() time.Duration {
+	if Config.Introspection.CacheSeconds <= 0 {
+		return time.Duration(defaultIntrospectionCacheSeconds) * time.Second
+	}
+
+	return time.Duration(Config.Introspection.CacheSeconds) * time.Second
+}
+
+// clusterAdmin is the part of *kafka.AdminClient used to describe and manage clusters and topics.
+type clusterAdmin interface {
+	GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error)
+	DescribeConfigs(ctx context.Context, resources []kafka.ConfigResource, options ...kafka.DescribeConfigsAdminOption) ([]kafka.ConfigResourceResult, error)
+	CreateTopics(ctx context.Context, topics []kafka.TopicSpecification, options ...kafka.CreateTopicsAdminOption) ([]kafka.TopicResult, error)
+	CreatePartitions(ctx context.Context, partitions []kafka.PartitionsSpecification, options ...kafka.CreatePartitionsAdminOption) ([]kafka.TopicResult, error)
+	AlterConfigs(ctx context.Context, resources []kafka.ConfigResource, options ...kafka.AlterConfigsAdminOption) ([]kafka.ConfigResourceResult, error)
+}
+
+// newClusterAdmin derives an admin client from the default producer of the cluster, sharing its connections.
+var newClusterAdmin = func(ctx context.Context, cluster string) (clusterAdmin, error) {
+	p, err := getProducer(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := kafka.NewAdminClientFromProducer(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return ac, nil
+}
+
+// metadataCache caches the metadata and topic configs, unfiltered, so every caller shares them.
+type metadataCache struct {
+	mu      sync.Mutex
+	entries map[string]metadataCacheEntry
+}
+
+type metadataCacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+var introspectionCache = newMetadataCache()
+
+func newMetadataCache() *metadataCache {
+	return &metadataCache{
+		entries: map[string]metadataCacheEntry{},
+	}
+}
+
+// Get returns the cached value of the key, calling load if it's missing or expired. Errors aren't cached.
+func (c *metadataCache) Get(key string, load func() (interface{}, error)) (interface{}, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = metadataCacheEntry{value, now.Add(introspectionCacheTTL())}
+
+	return value, nil
+}
+
+// Delete removes the keys, e.g. once a topic changed.
+func (c *metadataCache) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+}
+
+type brokerInfo struct {
+	ID   int32  `json:"id"`
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type clusterConnection struct {
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checkedAt,omitempty"`
+}
+
+type clusterInfo struct {
+	Name    string       `json:"name"`
+	Brokers []brokerInfo `json:"brokers"`
+	// OriginatingBroker answered the metadata request, the controller isn't exposed by the client.
+	OriginatingBroker *brokerInfo       `json:"originatingBroker,omitempty"`
+	Connection        clusterConnection `json:"connection"`
+}
+
+type topicSummary struct {
+	Name              string `json:"name"`
+	Partitions        int    `json:"partitions"`
+	ReplicationFactor int    `json:"replicationFactor"`
+}
+
+type partitionInfo struct {
+	ID       int32   `json:"id"`
+	Leader   int32   `json:"leader"`
+	Replicas []int32 `json:"replicas"`
+	ISR      []int32 `json:"isr"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type topicInfo struct {
+	topicSummary
+	UnderReplicatedPartitions int               `json:"underReplicatedPartitions"`
+	PartitionDetails          []partitionInfo   `json:"partitionDetails"`
+	Configs                   map[string]string `json:"configs"`
+}
+
+// GetCluster returns the brokers and connection state of the cluster.
+func (rh router) GetCluster(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	cluster := mux.Vars(r)["cluster"]
+
+	if !authorized(w, r) {
+		return
+	}
+
+	if !clusterVisible(principal(r), cluster) {
+		writeErrorResponse(w, log, "", newClusterNotFoundError(cluster))
+		return
+	}
+
+	info := clusterInfo{Name: cluster, Brokers: []brokerInfo{}}
+
+	md, err := clusterMetadata(r.Context(), cluster)
+	if err != nil {
+		if ae := classifyError(err); ae.Code == codeClusterNotFound {
+			writeErrorResponse(w, log, "", ae)
+			return
+		}
+
+		log.Warn().Err(err).Msgf("error fetching the metadata of cluster %s", cluster)
+		info.Connection = clusterConnection{State: connectionDisconnected, Error: "error fetching the metadata"}
+
+		if Config.ExposeErrorDetails {
+			info.Connection.Error = err.Error()
+		}
+	} else {
+		for _, b := range md.Brokers {
+			info.Brokers = append(info.Brokers, brokerInfo{b.ID, b.Host, b.Port})
+		}
+
+		sort.Slice(info.Brokers, func(i, j int) bool { return info.Brokers[i].ID < info.Brokers[j].ID })
+
+		info.OriginatingBroker = &brokerInfo{md.OriginatingBroker.ID, md.OriginatingBroker.Host, md.OriginatingBroker.Port}
+		info.Connection = clusterConnection{State: connectionConnected}
+	}
+
+	// the last health check, which may be more recent than the cached metadata
+	if state, ok := clusterHealthChecker.State(cluster); ok {
+		checkedAt := state.CheckedAt
+		info.Connection.CheckedAt = &checkedAt
+
+		if !state.Healthy && err == nil {
+			info.Connection.State = connectionDisconnected
+			info.Connection.Error = "last health check failed"
+		}
+	}
+
+	writeJSON(w, log, http.StatusOK, info)
+}
+
+// GetTopics returns the topics of the cluster the caller may see.
+func (rh router) GetTopics(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	cluster := mux.Vars(r)["cluster"]
+
+	if !authorized(w, r) {
+		return
+	}
+
+	p := principal(r)
+
+	if !clusterVisible(p, cluster) {
+		writeErrorResponse(w, log, "", newClusterNotFoundError(cluster))
+		return
+	}
+
+	md, err := clusterMetadata(r.Context(), cluster)
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	topics := []topicSummary{}
+	for name, tm := range md.Topics {
+		// topics the proxy itself isn't authorized for are reported with an error
+		if tm.Error.Code() != kafka.ErrNoError || !topicVisible(p, cluster, name) {
+			continue
+		}
+
+		topics = append(topics, newTopicSummary(tm))
+	}
+
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+
+	writeJSON(w, log, http.StatusOK, map[string]interface{}{
+		"cluster": cluster,
+		"topics":  topics,
+	})
+}
+
+// GetTopic returns the partitions, leaders, in-sync replicas and key configs of the topic.
+func (rh router) GetTopic(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	vars := mux.Vars(r)
+	cluster, topic := vars["cluster"], vars["topic"]
+
+	if !authorized(w, r) {
+		return
+	}
+
+	p := principal(r)
+
+	if !clusterVisible(p, cluster) {
+		writeErrorResponse(w, log, "", newClusterNotFoundError(cluster))
+		return
+	}
+
+	// topics the caller may not see are reported as missing
+	if !topicVisible(p, cluster, topic) {
+		writeErrorResponse(w, log, "", newTopicNotFoundError(topic, nil))
+		return
+	}
+
+	md, err := clusterMetadata(r.Context(), cluster)
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	tm, ok := md.Topics[topic]
+	if !ok || tm.Error.Code() != kafka.ErrNoError {
+		writeErrorResponse(w, log, "", newTopicNotFoundError(topic, nil))
+		return
+	}
+
+	configs, err := topicConfigs(r.Context(), cluster, topic)
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	info := topicInfo{
+		topicSummary:     newTopicSummary(tm),
+		PartitionDetails: []partitionInfo{},
+		Configs:          configs,
+	}
+
+	for _, pm := range tm.Partitions {
+		pi := partitionInfo{
+			ID:       pm.ID,
+			Leader:   pm.Leader,
+			Replicas: pm.Replicas,
+			ISR:      pm.Isrs,
+		}
+
+		if pm.Error.Code() != kafka.ErrNoError {
+			pi.Error = pm.Error.Code().String()
+		}
+
+		if len(pm.Isrs) < len(pm.Replicas) {
+			info.UnderReplicatedPartitions++
+		}
+
+		info.PartitionDetails = append(info.PartitionDetails, pi)
+	}
+
+	sort.Slice(info.PartitionDetails, func(i, j int) bool { return info.PartitionDetails[i].ID < info.PartitionDetails[j].ID })
+
+	writeJSON(w, log, http.StatusOK, info)
+}
+
+func newTopicSummary(tm kafka.TopicMetadata) topicSummary {
+	ts := topicSummary{
+		Name:       tm.Topic,
+		Partitions: len(tm.Partitions),
+	}
+
+	if len(tm.Partitions) > 0 {
+		ts.ReplicationFactor = len(tm.Partitions[0].Replicas)
+	}
+
+	return ts
+}
+
+func metadataCacheKey(cluster string) string {
+	return fmt.Sprintf("metadata/%s", cluster)
+}
+
+func configsCacheKey(cluster, topic string) string {
+	return fmt.Sprintf("configs/%s/%s", cluster, topic)
+}
+
+// clusterMetadata returns the cached metadata of every topic of the cluster.
+func clusterMetadata(ctx context.Context, cluster string) (*kafka.Metadata, error) {
+	v, err := introspectionCache.Get(metadataCacheKey(cluster), func() (interface{}, error) {
+		ac, err := newClusterAdmin(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		return ac.GetMetadata(nil, true, introspectionTimeoutMs)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*kafka.Metadata), nil
+}
+
+// topicConfigs returns the cached key configs of the topic.
+func topicConfigs(ctx context.Context, cluster, topic string) (map[string]string, error) {
+	v, err := introspectionCache.Get(configsCacheKey(cluster, topic), func() (interface{}, error) {
+		ac, err := newClusterAdmin(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := ac.DescribeConfigs(ctx,
+			[]kafka.ConfigResource{{Type: kafka.ResourceTopic, Name: topic}},
+			kafka.SetAdminRequestTimeout(time.Duration(introspectionTimeoutMs)*time.Millisecond))
+		if err != nil {
+			return nil, err
+		}
+
+		configs := map[string]string{}
+
+		for _, result := range results {
+			if result.Error.Code() != kafka.ErrNoError {
+				return nil, result.Error
+			}
+
+			for name, entry := range result.Config {
+				if contains(keyTopicConfigs, name) && !entry.IsSensitive {
+					configs[name] = entry.Value
+				}
+			}
+		}
+
+		return configs, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(map[string]string), nil
+}
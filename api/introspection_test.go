@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClusterAdmin struct {
+	metadataCalls int
+	configCalls   int
+	err           error
+
+	created      []kafka.TopicSpecification
+	partitions   []kafka.PartitionsSpecification
+	altered      []kafka.ConfigResource
+	validateOnly bool
+}
+
+func (a *fakeClusterAdmin) GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error) {
+	a.metadataCalls++
+
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	return &kafka.Metadata{
+		Brokers: []kafka.BrokerMetadata{
+			{ID: 2, Host: "broker-2", Port: 9092},
+			{ID: 1, Host: "broker-1", Port: 9092},
+		},
+		OriginatingBroker: kafka.BrokerMetadata{ID: 1, Host: "broker-1", Port: 9092},
+		Topics: map[string]kafka.TopicMetadata{
+			"orders": {Topic: "orders", Partitions: []kafka.PartitionMetadata{
+				{ID: 1, Leader: 2, Replicas: []int32{2, 1}, Isrs: []int32{2}},
+				{ID: 0, Leader: 1, Replicas: []int32{1, 2}, Isrs: []int32{1, 2}},
+			}},
+			"payments": {Topic: "payments", Partitions: []kafka.PartitionMetadata{
+				{ID: 0, Leader: 1, Replicas: []int32{1, 2}, Isrs: []int32{1, 2}},
+			}},
+			"secret": {Topic: "secret", Error: kafka.NewError(kafka.ErrTopicAuthorizationFailed, "", false)},
+		},
+	}, nil
+}
+
+func (a *fakeClusterAdmin) DescribeConfigs(ctx context.Context, resources []kafka.ConfigResource, options ...kafka.DescribeConfigsAdminOption) ([]kafka.ConfigResourceResult, error) {
+	a.configCalls++
+
+	config := map[string]kafka.ConfigEntryResult{
+		"retention.ms":        {Name: "retention.ms", Value: "604800000"},
+		"min.insync.replicas": {Name: "min.insync.replicas", Value: "2"},
+		"flush.ms":            {Name: "flush.ms", Value: "1000"},
+		"cleanup.policy":      {Name: "cleanup.policy", Value: "compact", Source: kafka.ConfigSourceDynamicTopic},
+	}
+
+	if resources[0].Name == "payments" {
+		config["sasl.jaas.config"] = kafka.ConfigEntryResult{Name: "sasl.jaas.config", Source: kafka.ConfigSourceDynamicTopic, IsSensitive: true}
+	}
+
+	return []kafka.ConfigResourceResult{{
+		Type:   kafka.ResourceTopic,
+		Name:   resources[0].Name,
+		Config: config,
+	}}, nil
+}
+
+func (a *fakeClusterAdmin) CreateTopics(ctx context.Context, topics []kafka.TopicSpecification, options ...kafka.CreateTopicsAdminOption) ([]kafka.TopicResult, error) {
+	a.created = append(a.created, topics...)
+	a.validateOnly = options[0] == kafka.SetAdminValidateOnly(true)
+
+	if topics[0].Topic == "orders" {
+		return []kafka.TopicResult{{Topic: "orders", Error: kafka.NewError(kafka.ErrTopicAlreadyExists, "", false)}}, nil
+	}
+
+	return []kafka.TopicResult{{Topic: topics[0].Topic}}, nil
+}
+
+func (a *fakeClusterAdmin) CreatePartitions(ctx context.Context, partitions []kafka.PartitionsSpecification, options ...kafka.CreatePartitionsAdminOption) ([]kafka.TopicResult, error) {
+	a.partitions = append(a.partitions, partitions...)
+	a.validateOnly = options[0] == kafka.SetAdminValidateOnly(true)
+
+	return []kafka.TopicResult{{Topic: partitions[0].Topic}}, nil
+}
+
+func (a *fakeClusterAdmin) AlterConfigs(ctx context.Context, resources []kafka.ConfigResource, options ...kafka.AlterConfigsAdminOption) ([]kafka.ConfigResourceResult, error) {
+	a.altered = append(a.altered, resources...)
+	a.validateOnly = options[0] == kafka.SetAdminValidateOnly(true)
+
+	return []kafka.ConfigResourceResult{{Type: kafka.ResourceTopic, Name: resources[0].Name}}, nil
+}
+
+func introspectionRouter(t *testing.T, admin *fakeClusterAdmin) *mux.Router {
+	setup()
+	introspectionCache = newMetadataCache()
+
+	f := newClusterAdmin
+	t.Cleanup(func() {
+		newClusterAdmin = f
+		Config.Introspection = introspectionConfig{}
+		Config.TopicAdmin = topicAdminConfig{}
+		topicAdminPolicy = &topicPolicy{}
+		introspectionCache = newMetadataCache()
+	})
+
+	newClusterAdmin = func(ctx context.Context, cluster string) (clusterAdmin, error) {
+		if cluster != "kafka-cl01" {
+			return nil, newClusterNotFoundError(cluster)
+		}
+
+		return admin, nil
+	}
+
+	mr := mux.NewRouter()
+	configureRouter(mr, &countingProducer{})
+
+	return mr
+}
+
+func introspectionRequest(mr *mux.Router, path string, v interface{}) int {
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("X-API-TOKEN", Secrets.APIToken)
+
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, req)
+
+	if v != nil {
+		json.Unmarshal(w.Body.Bytes(), v)
+	}
+
+	return w.Code
+}
+
+func TestGetCluster(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	mr := introspectionRouter(t, admin)
+
+	var info clusterInfo
+	assert.Equal(t, http.StatusOK, introspectionRequest(mr, "/clusters/kafka-cl01", &info))
+	assert.Equal(t, "kafka-cl01", info.Name)
+	assert.Equal(t, []brokerInfo{{1, "broker-1", 9092}, {2, "broker-2", 9092}}, info.Brokers)
+	assert.Equal(t, connectionConnected, info.Connection.State)
+
+	assert.Equal(t, http.StatusNotFound, introspectionRequest(mr, "/clusters/unknown", nil))
+}
+
+func TestGetClusterDisconnected(t *testing.T) {
+	mr := introspectionRouter(t, &fakeClusterAdmin{err: kafka.NewError(kafka.ErrAllBrokersDown, "all brokers down", false)})
+
+	var info clusterInfo
+	assert.Equal(t, http.StatusOK, introspectionRequest(mr, "/clusters/kafka-cl01", &info))
+	assert.Equal(t, connectionDisconnected, info.Connection.State)
+	assert.Empty(t, info.Brokers)
+}
+
+func TestGetTopics(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	mr := introspectionRouter(t, admin)
+
+	var resp struct {
+		Topics []topicSummary `json:"topics"`
+	}
+
+	assert.Equal(t, http.StatusOK, introspectionRequest(mr, "/clusters/kafka-cl01/topics", &resp))
+	assert.Equal(t, []topicSummary{{"orders", 2, 2}, {"payments", 1, 2}}, resp.Topics)
+
+	// served from the cache
+	introspectionRequest(mr, "/clusters/kafka-cl01/topics", nil)
+	assert.Equal(t, 1, admin.metadataCalls)
+}
+
+func TestGetTopic(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	mr := introspectionRouter(t, admin)
+
+	var info topicInfo
+	assert.Equal(t, http.StatusOK, introspectionRequest(mr, "/clusters/kafka-cl01/topics/orders", &info))
+	assert.Equal(t, "orders", info.Name)
+	assert.Equal(t, 1, info.UnderReplicatedPartitions)
+	assert.Equal(t, int32(0), info.PartitionDetails[0].ID)
+	assert.Equal(t, []int32{2}, info.PartitionDetails[1].ISR)
+	assert.Equal(t, map[string]string{"retention.ms": "604800000", "min.insync.replicas": "2", "cleanup.policy": "compact"}, info.Configs)
+
+	introspectionRequest(mr, "/clusters/kafka-cl01/topics/orders", nil)
+	assert.Equal(t, 1, admin.configCalls)
+
+	assert.Equal(t, http.StatusNotFound, introspectionRequest(mr, "/clusters/kafka-cl01/topics/secret", nil))
+	assert.Equal(t, http.StatusNotFound, introspectionRequest(mr, "/clusters/kafka-cl01/topics/unknown", nil))
+}
+
+func TestIntrospectionAccess(t *testing.T) {
+	mr := introspectionRouter(t, &fakeClusterAdmin{})
+
+	Config.EnableAPIAuth = true
+	defer func() {
+		Config.EnableAPIAuth = false
+	}()
+
+	Config.KafkaBrokerGroups = []string{"kafka-cl01", "kafka-cl02"}
+	Config.Introspection.Access = []introspectionAccess{
+		{Principal: principalOf(Secrets.APIToken, ""), Cluster: "kafka-cl01", Topics: []string{"pay*"}},
+	}
+
+	var clusters map[string][]string
+	assert.Equal(t, http.StatusOK, introspectionRequest(mr, "/clusters", &clusters))
+	assert.Equal(t, []string{"kafka-cl01"}, clusters["clusters"])
+
+	var resp struct {
+		Topics []topicSummary `json:"topics"`
+	}
+
+	assert.Equal(t, http.StatusOK, introspectionRequest(mr, "/clusters/kafka-cl01/topics", &resp))
+	assert.Equal(t, []topicSummary{{"payments", 1, 2}}, resp.Topics)
+
+	assert.Equal(t, http.StatusNotFound, introspectionRequest(mr, "/clusters/kafka-cl01/topics/orders", nil))
+	assert.Equal(t, http.StatusNotFound, introspectionRequest(mr, "/clusters/kafka-cl02", nil))
+}
+
+func TestMetadataCacheErrors(t *testing.T) {
+	c := newMetadataCache()
+
+	_, err := c.Get("key", func() (interface{}, error) { return nil, errors.New("failed") })
+	assert.Error(t, err)
+
+	v, err := c.Get("key", func() (interface{}, error) { return "value", nil })
+	assert.Nil(t, err)
+	assert.Equal(t, "value", v)
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is a single step of a jsonPath, a key, an index or a wildcard matching every key or index.
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// jsonPath is a small subset of JSONPath: `$` followed by `.key`, `['key']`, `[0]`, `.*` or `[*]` segments.
+type jsonPath struct {
+	expr     string
+	segments []pathSegment
+}
+
+// parseJSONPath parses the expression, e.g. `$.data.customer.ssn` or `$.data.items[*].id`.
+func parseJSONPath(expr string) (jsonPath, error) {
+	p := jsonPath{expr: expr}
+
+	if !strings.HasPrefix(expr, "$") {
+		return p, fmt.Errorf("invalid JSONPath '%s': must start with '$'", expr)
+	}
+
+	rest := expr[1:]
+	for len(rest) > 0 {
+		switch {
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return p, fmt.Errorf("invalid JSONPath '%s': unterminated ['", expr)
+			}
+
+			p.segments = append(p.segments, pathSegment{key: rest[2:end]})
+			rest = rest[end+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return p, fmt.Errorf("invalid JSONPath '%s': unterminated [", expr)
+			}
+
+			value := rest[1:end]
+			if value == "*" {
+				p.segments = append(p.segments, pathSegment{wildcard: true})
+			} else {
+				index, err := strconv.Atoi(value)
+				if err != nil || index < 0 {
+					return p, fmt.Errorf("invalid JSONPath '%s': invalid index '%s'", expr, value)
+				}
+
+				p.segments = append(p.segments, pathSegment{index: index, isIndex: true})
+			}
+
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+
+			key := rest[:end]
+			if len(key) == 0 {
+				return p, fmt.Errorf("invalid JSONPath '%s': empty key", expr)
+			}
+
+			p.segments = append(p.segments, pathSegment{key: key, wildcard: key == "*"})
+			rest = rest[end:]
+		default:
+			return p, fmt.Errorf("invalid JSONPath '%s': unexpected '%s'", expr, rest)
+		}
+	}
+
+	return p, nil
+}
+
+func (p jsonPath) String() string {
+	return p.expr
+}
+
+// Get returns the values matching the path.
+func (p jsonPath) Get(doc interface{}) []interface{} {
+	values := []interface{}{}
+
+	p.walk(doc, 0, func(parent interface{}, key string, index int) {
+		switch v := parent.(type) {
+		case map[string]interface{}:
+			values = append(values, v[key])
+		case []interface{}:
+			values = append(values, v[index])
+		}
+	})
+
+	return values
+}
+
+// Replace sets every value matching the path to the result of fn.
+func (p jsonPath) Replace(doc interface{}, fn func(value interface{}) interface{}) {
+	p.walk(doc, 0, func(parent interface{}, key string, index int) {
+		switch v := parent.(type) {
+		case map[string]interface{}:
+			v[key] = fn(v[key])
+		case []interface{}:
+			v[index] = fn(v[index])
+		}
+	})
+}
+
+// Set sets the value at the path, creating missing objects along the way. Wildcards are not supported.
+func (p jsonPath) Set(doc map[string]interface{}, value interface{}) error {
+	var node interface{} = doc
+
+	for i, seg := range p.segments {
+		last := i == len(p.segments)-1
+
+		if seg.wildcard {
+			return fmt.Errorf("can't set '%s': wildcards are not supported", p.expr)
+		}
+
+		switch v := node.(type) {
+		case map[string]interface{}:
+			if seg.isIndex {
+				return fmt.Errorf("can't set '%s': not an array", p.expr)
+			}
+
+			if last {
+				v[seg.key] = value
+				return nil
+			}
+
+			child, ok := v[seg.key]
+			if !ok || child == nil {
+				child = map[string]interface{}{}
+				v[seg.key] = child
+			}
+
+			node = child
+		case []interface{}:
+			if !seg.isIndex || seg.index >= len(v) {
+				return fmt.Errorf("can't set '%s': index out of range", p.expr)
+			}
+
+			if last {
+				v[seg.index] = value
+				return nil
+			}
+
+			node = v[seg.index]
+		default:
+			return fmt.Errorf("can't set '%s': not an object", p.expr)
+		}
+	}
+
+	return fmt.Errorf("can't set '%s': empty path", p.expr)
+}
+
+// Delete removes the object keys matching the path, array elements are left in place.
+func (p jsonPath) Delete(doc interface{}) {
+	p.walk(doc, 0, func(parent interface{}, key string, index int) {
+		if m, ok := parent.(map[string]interface{}); ok {
+			delete(m, key)
+		}
+	})
+}
+
+// walk calls fn with the parent and key or index of every existing value matching the path.
+func (p jsonPath) walk(node interface{}, depth int, fn func(parent interface{}, key string, index int)) {
+	if depth == len(p.segments) {
+		return
+	}
+
+	seg := p.segments[depth]
+	last := depth == len(p.segments)-1
+
+	visit := func(parent, child interface{}, key string, index int) {
+		if last {
+			fn(parent, key, index)
+		} else {
+			p.walk(child, depth+1, fn)
+		}
+	}
+
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if seg.isIndex {
+			return
+		}
+
+		if seg.wildcard {
+			for key, child := range v {
+				visit(v, child, key, 0)
+			}
+		} else if child, ok := v[seg.key]; ok {
+			visit(v, child, seg.key, 0)
+		}
+	case []interface{}:
+		if seg.wildcard {
+			for i, child := range v {
+				visit(v, child, "", i)
+			}
+		} else if seg.isIndex && seg.index < len(v) {
+			visit(v, v[seg.index], "", seg.index)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"$.data.ssn", true},
+		{"$.data['first name']", true},
+		{"$.data.items[0].id", true},
+		{"$.data.items[*].id", true},
+		{"$.data.*", true},
+		{"data.ssn", false},
+		{"$.data..ssn", false},
+		{"$.data.items[-1]", false},
+		{"$.data['ssn", false},
+	}
+
+	for _, test := range tests {
+		_, err := parseJSONPath(test.expr)
+		assert.Equal(t, test.valid, err == nil, test.expr)
+	}
+}
+
+func TestJSONPathGet(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"data": {"id": 1, "items": [{"id": "a"}, {"id": "b"}], "first name": "jane"}}`), &doc)
+
+	tests := []struct {
+		expr     string
+		expected []interface{}
+	}{
+		{"$.data.id", []interface{}{float64(1)}},
+		{"$.data.items[1].id", []interface{}{"b"}},
+		{"$.data.items[*].id", []interface{}{"a", "b"}},
+		{"$.data['first name']", []interface{}{"jane"}},
+		{"$.data.missing", []interface{}{}},
+		{"$.data.items[5].id", []interface{}{}},
+	}
+
+	for _, test := range tests {
+		p, err := parseJSONPath(test.expr)
+		assert.Nil(t, err, test.expr)
+		assert.Equal(t, test.expected, p.Get(doc), test.expr)
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultKeySeparator = ":"
+	keyHashSHA256       = "sha256"
+)
+
+// messageKeyRules are the compiled key extraction rules per topic.
+var messageKeyRules = keyRules{}
+
+// keyRuleConfig computes the key of a topic's messages from its data when the caller omits it.
+type keyRuleConfig struct {
+	// Paths are JSONPath expressions into the data, their values are joined by Separator.
+	Paths     []string `json:"paths"`
+	Separator string   `json:"separator,omitempty"`
+	// Hash replaces the key with its hex encoded digest, only sha256 is supported.
+	Hash string `json:"hash,omitempty"`
+}
+
+type keyRule struct {
+	paths     []jsonPath
+	separator string
+	hash      string
+}
+
+type keyRules map[string]keyRule
+
+// newKeyRules compiles the configured rule of every topic.
+func newKeyRules(config map[string]keyRuleConfig) (keyRules, error) {
+	rules := keyRules{}
+
+	for topic, rc := range config {
+		if len(rc.Paths) == 0 {
+			return nil, fmt.Errorf("key rule of topic '%s' requires at least one path", topic)
+		}
+
+		if len(rc.Hash) > 0 && rc.Hash != keyHashSHA256 {
+			return nil, fmt.Errorf("key rule of topic '%s' has an unknown hash '%s'", topic, rc.Hash)
+		}
+
+		rule := keyRule{separator: rc.Separator, hash: rc.Hash}
+		if len(rule.separator) == 0 {
+			rule.separator = defaultKeySeparator
+		}
+
+		for _, expr := range rc.Paths {
+			p, err := parseJSONPath(expr)
+			if err != nil {
+				return nil, fmt.Errorf("key rule of topic '%s': %w", topic, err)
+			}
+
+			rule.paths = append(rule.paths, p)
+		}
+
+		rules[topic] = rule
+	}
+
+	return rules, nil
+}
+
+// Apply sets the key from the data when the caller omitted it and the topic has a rule. Every
+// path must match a value, so messages are never produced with a partial key.
+func (kr keyRules) Apply(options ProduceOptions) (ProduceOptions, error) {
+	rule, ok := kr[options.Topic]
+	if !ok || !keyOmitted(options.Key) {
+		return options, nil
+	}
+
+	parts := make([]string, 0, len(rule.paths))
+	var single interface{}
+
+	for _, p := range rule.paths {
+		values := p.Get(options.Data)
+		if len(values) == 0 || values[0] == nil {
+			return options, &apiError{
+				Status:  http.StatusBadRequest,
+				Code:    codeValidationFailed,
+				Message: fmt.Sprintf("key is required or data must contain '%s'", p),
+				Fields:  []fieldError{{"key", fieldCodeRequired, fmt.Sprintf("key can't be derived, '%s' is missing", p)}},
+			}
+		}
+
+		single = values[0]
+		parts = append(parts, keyPart(values[0]))
+	}
+
+	switch {
+	case len(rule.hash) > 0:
+		options.Key = fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(parts, rule.separator))))
+	case len(parts) == 1:
+		// keep the type of a single value, e.g. a number
+		options.Key = single
+	default:
+		options.Key = strings.Join(parts, rule.separator)
+	}
+
+	return options, nil
+}
+
+func keyOmitted(key interface{}) bool {
+	if key == nil {
+		return true
+	}
+
+	s, ok := key.(string)
+	return ok && len(s) == 0
+}
+
+// keyPart returns strings as is and the JSON of any other value.
+func keyPart(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	b, _ := json.Marshal(v)
+	return string(b)
+}
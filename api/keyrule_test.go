@@ -0,0 +1,71 @@
+package api
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyRules(t *testing.T) {
+	data := map[string]interface{}{
+		"customer": map[string]interface{}{"id": "c1", "number": float64(42)},
+		"region":   "eu",
+	}
+
+	tests := []struct {
+		name string
+		rule keyRuleConfig
+		key  interface{}
+		want interface{}
+	}{
+		{"single path", keyRuleConfig{Paths: []string{"$.customer.id"}}, nil, "c1"},
+		{"single path keeps type", keyRuleConfig{Paths: []string{"$.customer.number"}}, "", float64(42)},
+		{"concatenated", keyRuleConfig{Paths: []string{"$.region", "$.customer.id"}}, nil, "eu:c1"},
+		{"custom separator", keyRuleConfig{Paths: []string{"$.region", "$.customer.number"}, Separator: "/"}, nil, "eu/42"},
+		{"hashed", keyRuleConfig{Paths: []string{"$.region", "$.customer.id"}, Hash: "sha256"}, nil, fmt.Sprintf("%x", sha256.Sum256([]byte("eu:c1")))},
+		{"caller key wins", keyRuleConfig{Paths: []string{"$.customer.id"}}, "explicit", "explicit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := newKeyRules(map[string]keyRuleConfig{"orders": tt.rule})
+			assert.Nil(t, err)
+
+			options, err := kr.Apply(ProduceOptions{Topic: "orders", Key: tt.key, Data: data})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, options.Key)
+		})
+	}
+}
+
+func TestKeyRulesMissingValue(t *testing.T) {
+	kr, err := newKeyRules(map[string]keyRuleConfig{"orders": {Paths: []string{"$.region", "$.customer.id"}}})
+	assert.Nil(t, err)
+
+	_, err = kr.Apply(ProduceOptions{Topic: "orders", Data: map[string]interface{}{"region": "eu"}})
+
+	var ae *apiError
+	assert.True(t, errors.As(err, &ae))
+	assert.Equal(t, http.StatusBadRequest, ae.Status)
+	assert.Equal(t, "key", ae.Fields[0].Field)
+
+	// topics without a rule are left alone
+	options, err := kr.Apply(ProduceOptions{Topic: "payments", Data: map[string]interface{}{}})
+	assert.Nil(t, err)
+	assert.Nil(t, options.Key)
+}
+
+func TestNewKeyRulesErrors(t *testing.T) {
+	for _, rc := range []keyRuleConfig{
+		{},
+		{Paths: []string{"customer.id"}},
+		{Paths: []string{"$.customer.id"}, Hash: "md5"},
+	} {
+		_, err := newKeyRules(map[string]keyRuleConfig{"orders": rc})
+		assert.NotNil(t, err, rc)
+	}
+}
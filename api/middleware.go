@@ -17,8 +17,10 @@ type contextKey string
 var producerctxkey = contextKey("producerctx")
 
 type producerCTX struct {
-	Cluster  string `json:"cluster"`
-	Instance *kafka.Producer
+	Cluster string `json:"cluster"`
+	// Durability is empty for the default producer of the cluster.
+	Durability string `json:"durability,omitempty"`
+	Instance   *kafka.Producer
 }
 
 var producerCTXs []producerCTX
@@ -31,29 +33,19 @@ type kafkaMiddleware struct{}
 func newKafkaMiddleware() (*kafkaMiddleware, error) {
 	producerCTXs = []producerCTX{}
 
-	// Create Producer instances
-	for _, kc := range Config.KafkaBrokerGroups {
-		cfg, err := kafkaClusterLookup(kc)
-
-		kcm := kafka.ConfigMap{
-			"enable.idempotence": cfg.Idempotence,
-			"bootstrap.servers":  cfg.BootstrapServers,
-			"security.protocol":  cfg.SecurityProtocol,
-		}
+	if err := validateProducerConfig(&Config); err != nil {
+		return nil, err
+	}
 
-		if Config.Debug {
-			kcm["debug"] = "all"
-		}
+	if err := validateDurabilityConfig(Config.Durability); err != nil {
+		return nil, err
+	}
 
-		if Config.UseKafkaCertAuth {
-			kcm["ssl.ca.location"] = KafkaCertFiles.CAChain
-			kcm["ssl.certificate.pem"] = KafkaCertFiles.CerRaw
-			kcm["ssl.key.pem"] = KafkaCertFiles.KeyRaw
-		} else {
-			kcm["ssl.ca.location"] = os.Getenv("KAFKA_PRODUCER_PROXY_SSL_CA_LOCATION")
-			kcm["sasl.mechanisms"] = cfg.SaslMechanisms
-			kcm["sasl.username"] = cfg.Username
-			kcm["sasl.password"] = cfg.Password
+	// Create Producer instances
+	for _, kc := range Config.KafkaBrokerGroups {
+		kcm, err := producerConfigMap(kc)
+		if err != nil {
+			return nil, err
 		}
 
 		kp, err := kafka.NewProducer(&kcm)
@@ -62,15 +54,82 @@ func newKafkaMiddleware() (*kafkaMiddleware, error) {
 			return nil, err
 		}
 
+		go watchEvents(kc, kp)
+
 		producerCTXs = append(producerCTXs, producerCTX{
 			Cluster:  kc,
 			Instance: kp,
 		})
+
+		// a producer per durability class since acks is a producer setting
+		for _, durability := range durabilityClasses() {
+			kcm, err := producerConfigMap(kc)
+			if err != nil {
+				return nil, err
+			}
+
+			applyDurability(durability, kcm)
+
+			kp, err := kafka.NewProducer(&kcm)
+			if err != nil {
+				return nil, err
+			}
+
+			go watchEvents(kc, kp)
+
+			producerCTXs = append(producerCTXs, producerCTX{
+				Cluster:    kc,
+				Durability: durability,
+				Instance:   kp,
+			})
+		}
 	}
 
 	return &kafkaMiddleware{}, nil
 }
 
+// producerConfigMap builds the librdkafka configuration for the cluster from the secrets.
+func producerConfigMap(cluster string) (kafka.ConfigMap, error) {
+	cfg, err := kafkaClusterLookup(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	kcm := clientConfigMap(cfg)
+	kcm["enable.idempotence"] = cfg.Idempotence
+
+	if err := applyProducerConfig(cluster, kcm); err != nil {
+		return nil, err
+	}
+
+	return kcm, nil
+}
+
+// clientConfigMap returns the connection and security settings shared by producers and consumers.
+func clientConfigMap(cfg KafkaConfig) kafka.ConfigMap {
+	kcm := kafka.ConfigMap{
+		"bootstrap.servers": cfg.BootstrapServers,
+		"security.protocol": cfg.SecurityProtocol,
+	}
+
+	if Config.Debug {
+		kcm["debug"] = "all"
+	}
+
+	if Config.UseKafkaCertAuth {
+		kcm["ssl.ca.location"] = KafkaCertFiles.CAChain
+		kcm["ssl.certificate.pem"] = KafkaCertFiles.CerRaw
+		kcm["ssl.key.pem"] = KafkaCertFiles.KeyRaw
+	} else {
+		kcm["ssl.ca.location"] = os.Getenv("KAFKA_PRODUCER_PROXY_SSL_CA_LOCATION")
+		kcm["sasl.mechanisms"] = cfg.SaslMechanisms
+		kcm["sasl.username"] = cfg.Username
+		kcm["sasl.password"] = cfg.Password
+	}
+
+	return kcm
+}
+
 // Handler adds the instance to the request context
 func (*kafkaMiddleware) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -79,18 +138,29 @@ func (*kafkaMiddleware) Handler(h http.Handler) http.Handler {
 	})
 }
 
-// getProducer retrieves the producer instance from context and Panics if not found
+// getProducer retrieves the default producer instance of the cluster from context
 func getProducer(ctx context.Context, cluster string) (*kafka.Producer, error) {
+	return getDurableProducer(ctx, cluster, "")
+}
+
+// getDurableProducer retrieves the producer instance of the cluster for the durability class from context
+func getDurableProducer(ctx context.Context, cluster, durability string) (*kafka.Producer, error) {
 	instance, ok := ctx.Value(producerctxkey).([]producerCTX)
 	if !ok {
 		return nil, errors.New("kafka producers were not found in context")
 	}
 
 	for _, p := range instance {
-		if strings.EqualFold(cluster, p.Cluster) {
+		if strings.EqualFold(cluster, p.Cluster) && strings.EqualFold(durability, p.Durability) {
 			return p.Instance, nil
 		}
 	}
 
-	return nil, fmt.Errorf("kafka producer with the name '%s' was not found", cluster)
+	if len(durability) > 0 {
+		e := newClusterNotFoundError(cluster)
+		e.Message = fmt.Sprintf("kafka producer with the name '%s' and durability '%s' was not found", cluster, durability)
+		return nil, e
+	}
+
+	return nil, newClusterNotFoundError(cluster)
 }
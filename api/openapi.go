@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+)
+
+// openAPIDoc is the parsed openAPISpec.
+var openAPIDoc = mustLoadOpenAPI(openAPISpec)
+
+func mustLoadOpenAPI(spec string) *openapi3.T {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(spec))
+	if err != nil {
+		panic(fmt.Sprintf("invalid OpenAPI spec: %s", err))
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		panic(fmt.Sprintf("invalid OpenAPI spec: %s", err))
+	}
+
+	return doc
+}
+
+// GetOpenAPI returns the OpenAPI document of the API.
+func (rh router) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, hlog.FromRequest(r), http.StatusOK, openAPIDoc)
+}
+
+// openAPIValidator validates the parameters and JSON bodies of requests against the OpenAPI document.
+// Only schema violations are rejected here, anything else, such as malformed JSON or an
+// unsupported content type, is left to the handler to report.
+func openAPIValidator(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := openAPIRoute(r)
+		if route == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: mux.Vars(r),
+			Route:      route,
+			Options: &openapi3filter.Options{
+				ExcludeRequestBody: !validatesBody(r),
+				MultiError:         true,
+				// the handlers authorize requests, since the API token is optional
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			// the validation errors describe the API, callers without a valid token only get a 401
+			if re := openAPIAuthorize(r, route.Path); re != nil {
+				writeErrorResponse(w, hlog.FromRequest(r), "", re)
+				return
+			}
+
+			if fields := openAPIFieldErrors(err); len(fields) > 0 {
+				// the properties of a schema are validated in map order
+				sort.SliceStable(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+
+				writeErrorResponse(w, hlog.FromRequest(r), "", validationError(fields))
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// openAPIAuthorize checks the API token as the handler of the path does. The topic admin and tail
+// routes always require it.
+func openAPIAuthorize(r *http.Request, path string) *apiError {
+	if strings.HasPrefix(path, "/admin/clusters/") || strings.HasSuffix(path, "/tail") {
+		return requireAPIToken(r)
+	}
+
+	return checkAPIToken(r.Header.Get("X-API-TOKEN"), r.RemoteAddr)
+}
+
+// openAPIRoute returns the operation of the matched mux route.
+func openAPIRoute(r *http.Request) *routers.Route {
+	mr := mux.CurrentRoute(r)
+	if mr == nil {
+		return nil
+	}
+
+	path, err := mr.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+
+	pathItem := openAPIDoc.Paths.Find(path)
+	if pathItem == nil {
+		return nil
+	}
+
+	op := pathItem.GetOperation(r.Method)
+	if op == nil {
+		return nil
+	}
+
+	return &routers.Route{
+		Spec:      openAPIDoc,
+		Path:      path,
+		PathItem:  pathItem,
+		Method:    r.Method,
+		Operation: op,
+	}
+}
+
+// validatesBody is true for JSON bodies the handler reads whole, streams, binary CloudEvents and
+// bodies larger than maxBodyBytes are left to the handler.
+func validatesBody(r *http.Request) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	return mt == "application/json" &&
+		!isCloudEvent(r) &&
+		r.ContentLength > 0 &&
+		r.ContentLength <= maxBodyBytes()
+}
+
+// openAPIFieldErrors returns a fieldError per schema violation of the error, none if the
+// request couldn't be validated.
+func openAPIFieldErrors(err error) []fieldError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		fields := []fieldError{}
+		for _, err := range e {
+			fields = append(fields, openAPIFieldErrors(err)...)
+		}
+
+		return fields
+	case *openapi3filter.RequestError:
+		if e.Parameter == nil {
+			return schemaFieldErrors(e.Err)
+		}
+
+		if e.Err == openapi3filter.ErrInvalidRequired {
+			return []fieldError{{e.Parameter.Name, fieldCodeRequired, fmt.Sprintf("%s is required", e.Parameter.Name)}}
+		}
+
+		return []fieldError{{e.Parameter.Name, fieldCodeInvalid, fmt.Sprintf("%s is invalid", e.Parameter.Name)}}
+	}
+
+	return nil
+}
+
+func schemaFieldErrors(err error) []fieldError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		fields := []fieldError{}
+		for _, err := range e {
+			fields = append(fields, schemaFieldErrors(err)...)
+		}
+
+		return fields
+	case *openapi3.SchemaError:
+		return []fieldError{{strings.Join(e.JSONPointer(), "."), schemaFieldCodes(e.SchemaField), e.Reason}}
+	}
+
+	return nil
+}
+
+// schemaFieldCodes maps the JSON schema keyword that failed to the code of the fieldError.
+func schemaFieldCodes(keyword string) string {
+	switch keyword {
+	case "required":
+		return fieldCodeRequired
+	case "maxLength":
+		return fieldCodeTooLong
+	case "pattern":
+		return fieldCodeInvalidCharacters
+	case "minimum", "maximum", "minLength", "minItems", "maxItems":
+		return fieldCodeOutOfRange
+	default:
+		return fieldCodeInvalid
+	}
+}
@@ -0,0 +1,688 @@
+package api
+
+// openAPISpec describes every route registered in configureRouter, it's served at /openapi.json
+// and requests are validated against it. TestOpenAPISpecMatchesRoutes keeps both in sync.
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "kafka-producer-proxy",
+    "description": "Produces JSON events to Kafka over HTTP.",
+    "version": "1.2.0"
+  },
+  "security": [{ "apiToken": [] }, {}],
+  "paths": {
+    "/ping": {
+      "get": {
+        "summary": "Indicates the API is up and ready for traffic",
+        "security": [],
+        "responses": {
+          "200": { "$ref": "#/components/responses/OK" }
+        }
+      }
+    },
+    "/health": {
+      "get": {
+        "summary": "Checks the health of every cluster",
+        "security": [],
+        "responses": {
+          "200": { "$ref": "#/components/responses/OK" },
+          "500": {
+            "description": "A cluster is unhealthy",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": { "errors": { "type": "string" } }
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "The OpenAPI document",
+            "content": { "application/json": { "schema": { "type": "object" } } }
+          }
+        }
+      }
+    },
+    "/events": {
+      "post": {
+        "summary": "Produces an event",
+        "description": "Also accepts CloudEvents in structured (application/cloudevents+json) and binary (ce-* headers) mode.",
+        "parameters": [{ "$ref": "#/components/parameters/idempotencyKey" }],
+        "requestBody": { "$ref": "#/components/requestBodies/Event" },
+        "responses": {
+          "200": { "$ref": "#/components/responses/Event" },
+          "202": { "$ref": "#/components/responses/Spooled" },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      },
+      "delete": {
+        "summary": "Produces an event",
+        "parameters": [{ "$ref": "#/components/parameters/idempotencyKey" }],
+        "requestBody": { "$ref": "#/components/requestBodies/Event" },
+        "responses": {
+          "200": { "$ref": "#/components/responses/Event" },
+          "202": { "$ref": "#/components/responses/Spooled" },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/events/stream": {
+      "post": {
+        "summary": "Produces every line of an NDJSON body as an event",
+        "requestBody": {
+          "required": true,
+          "description": "An EventRequest per line.",
+          "content": { "application/x-ndjson": { "schema": { "type": "string" } } }
+        },
+        "responses": {
+          "200": {
+            "description": "A StreamResult per line, in the order of the lines",
+            "content": { "application/x-ndjson": { "schema": { "$ref": "#/components/schemas/StreamResult" } } }
+          },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/events/ws": {
+      "get": {
+        "summary": "Upgrades to a WebSocket to produce events",
+        "responses": {
+          "101": { "description": "Switching protocols" },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/transactions": {
+      "post": {
+        "summary": "Produces the events atomically in a single transaction",
+        "requestBody": {
+          "required": true,
+          "content": { "application/json": { "schema": { "$ref": "#/components/schemas/TransactionRequest" } } }
+        },
+        "responses": {
+          "200": {
+            "description": "The transaction was committed",
+            "content": { "application/json": { "schema": { "$ref": "#/components/schemas/TransactionResponse" } } }
+          },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/clusters": {
+      "get": {
+        "summary": "Lists the clusters the caller may see",
+        "responses": {
+          "200": {
+            "description": "The cluster names",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": { "clusters": { "type": "array", "items": { "type": "string" } } }
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/clusters/{cluster}": {
+      "parameters": [{ "$ref": "#/components/parameters/cluster" }],
+      "get": {
+        "summary": "Returns the brokers and connection state of the cluster",
+        "responses": {
+          "200": {
+            "description": "The cluster",
+            "content": { "application/json": { "schema": { "$ref": "#/components/schemas/ClusterInfo" } } }
+          },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/clusters/{cluster}/topics": {
+      "parameters": [{ "$ref": "#/components/parameters/cluster" }],
+      "get": {
+        "summary": "Lists the topics of the cluster the caller may see",
+        "responses": {
+          "200": {
+            "description": "The topics",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "cluster": { "type": "string" },
+                    "topics": { "type": "array", "items": { "$ref": "#/components/schemas/TopicSummary" } }
+                  }
+                }
+              }
+            }
+          },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/clusters/{cluster}/topics/{topic}": {
+      "parameters": [{ "$ref": "#/components/parameters/cluster" }, { "$ref": "#/components/parameters/topic" }],
+      "get": {
+        "summary": "Returns the partitions and key configs of the topic",
+        "responses": {
+          "200": {
+            "description": "The topic",
+            "content": { "application/json": { "schema": { "$ref": "#/components/schemas/TopicInfo" } } }
+          },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/clusters/{cluster}/topics/{topic}/tail": {
+      "parameters": [{ "$ref": "#/components/parameters/cluster" }, { "$ref": "#/components/parameters/topic" }],
+      "get": {
+        "summary": "Streams recent and new messages of the topic as Server-Sent Events",
+        "parameters": [
+          { "name": "recent", "in": "query", "schema": { "type": "integer", "minimum": 0 } },
+          { "name": "limit", "in": "query", "schema": { "type": "integer", "minimum": 0 } },
+          { "name": "timeout", "in": "query", "schema": { "type": "integer", "minimum": 0 } }
+        ],
+        "responses": {
+          "200": {
+            "description": "message, end and error events",
+            "content": { "text/event-stream": { "schema": { "type": "string" } } }
+          },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/admin/spool": {
+      "get": {
+        "summary": "Returns the depth of the spool for each cluster",
+        "responses": {
+          "200": {
+            "description": "The spool statistics",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "enabled": { "type": "boolean" },
+                    "clusters": { "type": "object", "additionalProperties": { "$ref": "#/components/schemas/SpoolStats" } }
+                  }
+                }
+              }
+            }
+          },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/admin/clusters/{cluster}/topics": {
+      "parameters": [{ "$ref": "#/components/parameters/cluster" }, { "$ref": "#/components/parameters/dryRun" }],
+      "post": {
+        "summary": "Creates a topic",
+        "requestBody": {
+          "required": true,
+          "content": { "application/json": { "schema": { "$ref": "#/components/schemas/CreateTopicRequest" } } }
+        },
+        "responses": {
+          "200": { "$ref": "#/components/responses/TopicAdmin" },
+          "201": { "$ref": "#/components/responses/TopicAdmin" },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/admin/clusters/{cluster}/topics/{topic}/partitions": {
+      "parameters": [
+        { "$ref": "#/components/parameters/cluster" },
+        { "$ref": "#/components/parameters/topic" },
+        { "$ref": "#/components/parameters/dryRun" }
+      ],
+      "post": {
+        "summary": "Increases the partitions of the topic",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["partitions"],
+                "properties": { "partitions": { "type": "integer", "minimum": 1 } }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": { "$ref": "#/components/responses/TopicAdmin" },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/admin/clusters/{cluster}/topics/{topic}/configs": {
+      "parameters": [
+        { "$ref": "#/components/parameters/cluster" },
+        { "$ref": "#/components/parameters/topic" },
+        { "$ref": "#/components/parameters/dryRun" }
+      ],
+      "patch": {
+        "summary": "Sets configs of the topic, keeping the others",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["configs"],
+                "properties": { "configs": { "$ref": "#/components/schemas/Configs" } }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": { "$ref": "#/components/responses/TopicAdmin" },
+          "default": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/debug/vars": {
+      "get": {
+        "summary": "Metrics in the expvar format",
+        "responses": {
+          "200": {
+            "description": "The metrics",
+            "content": { "application/json": { "schema": { "type": "object" } } }
+          }
+        }
+      }
+    },
+    "/topics/{topic}": {
+      "parameters": [{ "$ref": "#/components/parameters/topic" }],
+      "post": {
+        "summary": "Confluent REST Proxy v2 compatible produce",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/vnd.kafka.json.v2+json": { "schema": { "$ref": "#/components/schemas/RestProxyV2Request" } },
+            "application/json": { "schema": { "$ref": "#/components/schemas/RestProxyV2Request" } }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "The offset or error of every record",
+            "content": { "application/vnd.kafka.v2+json": { "schema": { "$ref": "#/components/schemas/RestProxyV2Response" } } }
+          },
+          "default": { "$ref": "#/components/responses/RestProxyError" }
+        }
+      }
+    },
+    "/v3/clusters/{cluster}/topics/{topic}/records": {
+      "parameters": [{ "$ref": "#/components/parameters/cluster" }, { "$ref": "#/components/parameters/topic" }],
+      "post": {
+        "summary": "Confluent REST Proxy v3 compatible produce of a single record",
+        "requestBody": {
+          "required": true,
+          "content": { "application/json": { "schema": { "$ref": "#/components/schemas/RestProxyV3Request" } } }
+        },
+        "responses": {
+          "200": {
+            "description": "The record was produced",
+            "content": { "application/json": { "schema": { "$ref": "#/components/schemas/RestProxyV3Response" } } }
+          },
+          "default": { "$ref": "#/components/responses/RestProxyError" }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "apiToken": { "type": "apiKey", "in": "header", "name": "X-API-TOKEN" }
+    },
+    "parameters": {
+      "cluster": { "name": "cluster", "in": "path", "required": true, "schema": { "type": "string" } },
+      "topic": { "name": "topic", "in": "path", "required": true, "schema": { "type": "string" } },
+      "dryRun": {
+        "name": "dryRun",
+        "in": "query",
+        "description": "Only validate the change.",
+        "schema": { "type": "boolean" }
+      },
+      "idempotencyKey": {
+        "name": "Idempotency-Key",
+        "in": "header",
+        "description": "Deduplicates retries of the same request.",
+        "schema": { "type": "string" }
+      }
+    },
+    "requestBodies": {
+      "Event": {
+        "required": true,
+        "content": {
+          "application/json": { "schema": { "$ref": "#/components/schemas/EventRequest" } },
+          "application/cloudevents+json": { "schema": { "$ref": "#/components/schemas/CloudEvent" } }
+        }
+      }
+    },
+    "responses": {
+      "OK": {
+        "description": "OK",
+        "content": { "text/plain": { "schema": { "type": "string" } } }
+      },
+      "Event": {
+        "description": "The event was produced",
+        "content": { "application/json": { "schema": { "$ref": "#/components/schemas/EventResponse" } } }
+      },
+      "Spooled": {
+        "description": "The cluster is unavailable, the event was spooled",
+        "content": { "application/json": { "schema": { "$ref": "#/components/schemas/EventResponse" } } }
+      },
+      "TopicAdmin": {
+        "description": "The change was made, or validated for a dry run",
+        "content": { "application/json": { "schema": { "$ref": "#/components/schemas/TopicAdminResponse" } } }
+      },
+      "Error": {
+        "description": "The request failed, see the code",
+        "headers": {
+          "Retry-After": { "description": "Seconds to wait before retrying.", "schema": { "type": "integer" } }
+        },
+        "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Error" } } }
+      },
+      "RestProxyError": {
+        "description": "The request failed",
+        "content": {
+          "application/vnd.kafka.v2+json": { "schema": { "$ref": "#/components/schemas/RestProxyError" } },
+          "application/json": { "schema": { "$ref": "#/components/schemas/RestProxyError" } }
+        }
+      }
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "required": ["code", "retriable"],
+        "properties": {
+          "error": { "type": "string", "description": "Details, only if exposeErrorDetails is set." },
+          "message": { "type": "string" },
+          "code": {
+            "type": "string",
+            "enum": [
+              "invalid_json", "unknown_field", "validation_failed", "unauthorized", "forbidden",
+              "cluster_not_found", "topic_not_found", "not_enabled", "idempotency_conflict", "topic_exists",
+              "body_too_large", "message_too_large", "unsupported_media_type", "rate_limited",
+              "producer_busy", "cluster_unavailable", "timeout", "internal_error"
+            ]
+          },
+          "retriable": { "type": "boolean" },
+          "fields": { "type": "array", "items": { "$ref": "#/components/schemas/FieldError" } },
+          "requestId": { "type": "string" }
+        }
+      },
+      "FieldError": {
+        "type": "object",
+        "properties": {
+          "field": { "type": "string" },
+          "code": { "type": "string" },
+          "message": { "type": "string" }
+        }
+      },
+      "EventRequest": {
+        "type": "object",
+        "required": ["cluster", "topic"],
+        "properties": {
+          "cluster": { "type": "string" },
+          "topic": { "type": "string" },
+          "key": { "description": "Any JSON value, derived from the data by keyRules when omitted.", "nullable": true },
+          "data": { "type": "object", "nullable": true },
+          "durability": { "type": "string" }
+        }
+      },
+      "CloudEvent": {
+        "type": "object",
+        "required": ["specversion", "id", "source", "type"],
+        "properties": {
+          "specversion": { "type": "string", "enum": ["1.0"] },
+          "id": { "type": "string" },
+          "source": { "type": "string" },
+          "type": { "type": "string" },
+          "time": { "type": "string", "format": "date-time" },
+          "kafkacluster": { "type": "string" },
+          "kafkatopic": { "type": "string" },
+          "partitionkey": { "type": "string" },
+          "data": {}
+        }
+      },
+      "EventResponse": {
+        "type": "object",
+        "properties": {
+          "message": { "type": "string" },
+          "cluster": { "type": "string" }
+        }
+      },
+      "StreamResult": {
+        "type": "object",
+        "properties": {
+          "line": { "type": "integer" },
+          "status": { "type": "integer" },
+          "message": { "type": "string" },
+          "cluster": { "type": "string" },
+          "code": { "type": "string" },
+          "retriable": { "type": "boolean" },
+          "fields": { "type": "array", "items": { "$ref": "#/components/schemas/FieldError" } }
+        }
+      },
+      "TransactionRequest": {
+        "type": "object",
+        "required": ["cluster", "events"],
+        "properties": {
+          "cluster": { "type": "string" },
+          "events": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["topic"],
+              "properties": {
+                "topic": { "type": "string" },
+                "key": { "nullable": true },
+                "data": { "type": "object", "nullable": true }
+              }
+            }
+          }
+        }
+      },
+      "TransactionResponse": {
+        "type": "object",
+        "properties": {
+          "message": { "type": "string" },
+          "messages": { "type": "array", "items": { "type": "string" } }
+        }
+      },
+      "Broker": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "integer" },
+          "host": { "type": "string" },
+          "port": { "type": "integer" }
+        }
+      },
+      "ClusterInfo": {
+        "type": "object",
+        "properties": {
+          "name": { "type": "string" },
+          "brokers": { "type": "array", "items": { "$ref": "#/components/schemas/Broker" } },
+          "originatingBroker": { "$ref": "#/components/schemas/Broker" },
+          "connection": {
+            "type": "object",
+            "properties": {
+              "state": { "type": "string", "enum": ["connected", "disconnected"] },
+              "error": { "type": "string" },
+              "checkedAt": { "type": "string", "format": "date-time" }
+            }
+          }
+        }
+      },
+      "TopicSummary": {
+        "type": "object",
+        "properties": {
+          "name": { "type": "string" },
+          "partitions": { "type": "integer" },
+          "replicationFactor": { "type": "integer" }
+        }
+      },
+      "TopicInfo": {
+        "allOf": [
+          { "$ref": "#/components/schemas/TopicSummary" },
+          {
+            "type": "object",
+            "properties": {
+              "underReplicatedPartitions": { "type": "integer" },
+              "partitionDetails": {
+                "type": "array",
+                "items": {
+                  "type": "object",
+                  "properties": {
+                    "id": { "type": "integer" },
+                    "leader": { "type": "integer" },
+                    "replicas": { "type": "array", "items": { "type": "integer" } },
+                    "isr": { "type": "array", "items": { "type": "integer" } },
+                    "error": { "type": "string" }
+                  }
+                }
+              },
+              "configs": { "$ref": "#/components/schemas/Configs" }
+            }
+          }
+        ]
+      },
+      "Configs": {
+        "type": "object",
+        "additionalProperties": { "type": "string" }
+      },
+      "SpoolStats": {
+        "type": "object",
+        "properties": {
+          "depth": { "type": "integer" },
+          "bytes": { "type": "integer" },
+          "segments": { "type": "integer" },
+          "oldest": { "type": "string", "format": "date-time" }
+        }
+      },
+      "CreateTopicRequest": {
+        "type": "object",
+        "required": ["name", "partitions"],
+        "properties": {
+          "name": { "type": "string" },
+          "partitions": { "type": "integer", "minimum": 1 },
+          "replicationFactor": { "type": "integer", "minimum": 1 },
+          "configs": { "$ref": "#/components/schemas/Configs" }
+        }
+      },
+      "TopicAdminResponse": {
+        "type": "object",
+        "properties": {
+          "message": { "type": "string" },
+          "cluster": { "type": "string" },
+          "topic": { "type": "string" },
+          "dryRun": { "type": "boolean" }
+        }
+      },
+      "RestProxyV2Request": {
+        "type": "object",
+        "required": ["records"],
+        "properties": {
+          "records": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "key": { "nullable": true },
+                "value": { "nullable": true, "description": "Only JSON objects are supported, other values are rejected with 422" },
+                "partition": { "type": "integer" }
+              }
+            }
+          },
+          "key_schema": {},
+          "key_schema_id": {},
+          "value_schema": {},
+          "value_schema_id": {}
+        }
+      },
+      "RestProxyV2Response": {
+        "type": "object",
+        "properties": {
+          "key_schema_id": { "type": "integer", "nullable": true },
+          "value_schema_id": { "type": "integer", "nullable": true },
+          "offsets": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "partition": { "type": "integer", "nullable": true },
+                "offset": { "type": "integer", "nullable": true },
+                "error_code": { "type": "integer", "nullable": true },
+                "error": { "type": "string", "nullable": true }
+              }
+            }
+          }
+        }
+      },
+      "RestProxyV3Data": {
+        "type": "object",
+        "properties": {
+          "type": { "type": "string", "enum": ["JSON", "STRING"] },
+          "data": {}
+        }
+      },
+      "RestProxyV3Request": {
+        "type": "object",
+        "properties": {
+          "partition_id": { "type": "integer" },
+          "headers": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "name": { "type": "string" },
+                "value": { "type": "string", "format": "byte" }
+              }
+            }
+          },
+          "key": { "$ref": "#/components/schemas/RestProxyV3Data" },
+          "value": { "$ref": "#/components/schemas/RestProxyV3Data" },
+          "timestamp": { "type": "string", "format": "date-time" }
+        }
+      },
+      "RestProxyV3Response": {
+        "type": "object",
+        "properties": {
+          "error_code": { "type": "integer" },
+          "cluster_id": { "type": "string" },
+          "topic_name": { "type": "string" },
+          "partition_id": { "type": "integer" },
+          "offset": { "type": "integer" },
+          "timestamp": { "type": "string", "format": "date-time" },
+          "key": { "$ref": "#/components/schemas/RestProxyV3Size" },
+          "value": { "$ref": "#/components/schemas/RestProxyV3Size" }
+        }
+      },
+      "RestProxyV3Size": {
+        "type": "object",
+        "properties": {
+          "type": { "type": "string" },
+          "size": { "type": "integer" }
+        }
+      },
+      "RestProxyError": {
+        "type": "object",
+        "properties": {
+          "error_code": { "type": "integer" },
+          "message": { "type": "string" }
+        }
+      }
+    }
+  }
+}`
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOpenAPISpecMatchesRoutes fails when a route is added without describing it, or the other way around.
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	mr := mux.NewRouter()
+	configureRouter(mr, newProducer())
+
+	registered := map[string]bool{}
+
+	mr.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		assert.Nil(t, err)
+
+		methods, err := route.GetMethods()
+		assert.Nil(t, err)
+
+		for _, method := range methods {
+			registered[method+" "+path] = true
+
+			pathItem := openAPIDoc.Paths.Find(path)
+			if assert.NotNil(t, pathItem, "%s is missing from the OpenAPI spec", path) {
+				assert.NotNil(t, pathItem.GetOperation(method), "%s %s is missing from the OpenAPI spec", method, path)
+			}
+		}
+
+		return nil
+	})
+
+	for path, pathItem := range openAPIDoc.Paths {
+		for method := range pathItem.Operations() {
+			assert.True(t, registered[method+" "+path], "%s %s is not a route", method, path)
+		}
+	}
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	setup()
+
+	mr := mux.NewRouter()
+	configureRouter(mr, &countingProducer{})
+
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	doc, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.NotNil(t, doc.Paths.Find("/events").Post)
+}
+
+func TestOpenAPIValidator(t *testing.T) {
+	setup()
+
+	p := &countingProducer{}
+	mr := mux.NewRouter()
+	mr.Use(openAPIValidator)
+	configureRouter(mr, p)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		fields      []fieldError
+	}{
+		{
+			name:        "valid",
+			method:      "POST",
+			path:        "/events",
+			contentType: "application/json",
+			body:        `{"cluster":"kafka-cl01","topic":"orders","key":1,"data":{"id":1}}`,
+			status:      http.StatusOK,
+		},
+		{
+			name:        "wrong type",
+			method:      "POST",
+			path:        "/events",
+			contentType: "application/json",
+			body:        `{"cluster":1,"topic":"orders","data":[]}`,
+			status:      http.StatusBadRequest,
+			code:        codeValidationFailed,
+			fields: []fieldError{
+				{"cluster", fieldCodeInvalid, `Field must be set to string or not be present`},
+				{"data", fieldCodeInvalid, `Field must be set to object or not be present`},
+			},
+		},
+		{
+			name:        "missing field",
+			method:      "POST",
+			path:        "/events",
+			contentType: "application/json",
+			body:        `{"cluster":"kafka-cl01"}`,
+			status:      http.StatusBadRequest,
+			code:        codeValidationFailed,
+			fields:      []fieldError{{"topic", fieldCodeRequired, `property "topic" is missing`}},
+		},
+		{
+			name:        "malformed JSON is left to the handler",
+			method:      "POST",
+			path:        "/events",
+			contentType: "application/json",
+			body:        `{"cluster":`,
+			status:      http.StatusBadRequest,
+			code:        codeInvalidJSON,
+		},
+		{
+			name:   "invalid query parameter",
+			method: "GET",
+			path:   "/clusters/kafka-cl01/topics/orders/tail?limit=all",
+			status: http.StatusBadRequest,
+			code:   codeValidationFailed,
+			fields: []fieldError{{"limit", fieldCodeInvalid, "limit is invalid"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("X-API-TOKEN", Secrets.APIToken)
+
+			if len(tt.contentType) > 0 {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			w := httptest.NewRecorder()
+			mr.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+
+			if len(tt.code) > 0 {
+				var resp errorResponse
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, tt.code, resp.Code)
+				assert.Equal(t, tt.fields, resp.Fields)
+			}
+		})
+	}
+}
+
+func TestOpenAPIValidatorUnauthorized(t *testing.T) {
+	setup()
+	Config.EnableAPIAuth = true
+	defer func() {
+		Config.EnableAPIAuth = false
+	}()
+
+	mr := mux.NewRouter()
+	mr.Use(openAPIValidator)
+	configureRouter(mr, &countingProducer{})
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"no token", "/events", "", http.StatusUnauthorized},
+		{"wrong token", "/events", "testapitoken", http.StatusUnauthorized},
+		{"token", "/events", "TestApiToken", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(`{"cluster":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			if len(tt.token) > 0 {
+				req.Header.Set("X-API-TOKEN", tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			mr.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+
+			var resp errorResponse
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.status == http.StatusBadRequest, len(resp.Fields) > 0)
+		})
+	}
+
+	// the tail always requires the token
+	Config.EnableAPIAuth = false
+
+	req := httptest.NewRequest("GET", "/clusters/kafka-cl01/topics/orders/tail?limit=all", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -5,15 +5,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/rs/zerolog"
 )
 
+const failoverHeader = "x-kafka-producer-proxy-failover-from"
+
 // Result .
 type Result struct {
 	Message string
+	// Cluster is the cluster the message was produced to, which differs from the requested
+	// cluster when a failover occurred.
+	Cluster string
+	// Partition, Offset and Timestamp are from the delivery report.
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	// KeySize and ValueSize are the serialized sizes of the produced message, after the transforms
+	// and key rules.
+	KeySize   int
+	ValueSize int
+	// Spooled is true if the message was spooled to be produced once the cluster recovers.
+	Spooled bool
 	Error   error
 }
 
@@ -25,10 +42,16 @@ type ProduceOptions struct {
 	Topic   string
 	Key     interface{}
 	Data    map[string]interface{}
+	// Durability selects the producer by acks setting, empty uses the default producer.
+	Durability string
+	// Principal identifies the caller, used by the transformation pipeline.
+	Principal string
+	Headers   map[string]string
 }
 
 type kafkaProducer interface {
 	Produce(options ProduceOptions) *Result
+	ProduceTransaction(options TransactionOptions) *TransactionResult
 }
 
 type producer struct{}
@@ -37,9 +60,96 @@ func newProducer() kafkaProducer {
 	return &producer{}
 }
 
-// Produce publishes the message to Kafka.
+// Produce publishes the message to Kafka, failing over to the configured fallback clusters
+// when the requested cluster is unhealthy or a retriable broker error occurs.
 func (p producer) Produce(options ProduceOptions) *Result {
-	instance, err := getProducer(options.Context, options.Cluster)
+	options, err := prepareProduceOptions(options)
+	if err != nil {
+		return &Result{Error: err}
+	}
+
+	clusters := failoverClusters(options.Cluster)
+
+	var result *Result
+	for i, cluster := range clusters {
+		last := i == len(clusters)-1
+
+		if !last && !clusterHealthChecker.IsHealthy(cluster) {
+			options.Log.Warn().Msgf("cluster %s is unhealthy, skipping", cluster)
+			continue
+		}
+
+		result = p.produce(options, cluster)
+		result.Cluster = cluster
+
+		if result.Error == nil || last || !isRetriableBrokerError(result.Error) {
+			break
+		}
+
+		options.Log.Warn().Err(result.Error).Msgf("produce to cluster %s failed, failing over", cluster)
+	}
+
+	return result
+}
+
+// prepareProduceOptions resolves the durability and applies the transforms and key rules of the topic.
+func prepareProduceOptions(options ProduceOptions) (ProduceOptions, error) {
+	durability, err := resolveDurability(options.Topic, options.Durability)
+	if err != nil {
+		return options, err
+	}
+	options.Durability = durability
+
+	options = payloadTransforms.Apply(options)
+
+	return messageKeyRules.Apply(options)
+}
+
+// failoverClusters returns the requested cluster followed by its fallbacks.
+func failoverClusters(cluster string) []string {
+	clusters := []string{cluster}
+
+	for primary, fallbacks := range Config.KafkaFailover {
+		if strings.EqualFold(primary, cluster) {
+			clusters = append(clusters, fallbacks...)
+			break
+		}
+	}
+
+	return clusters
+}
+
+// isRetriableBrokerError returns true if the error indicates the cluster could not be reached,
+// meaning the message may be produced to another cluster.
+func isRetriableBrokerError(err error) bool {
+	var ke kafka.Error
+	if !errors.As(err, &ke) {
+		return false
+	}
+
+	if ke.IsRetriable() {
+		return true
+	}
+
+	switch ke.Code() {
+	case kafka.ErrTransport,
+		kafka.ErrAllBrokersDown,
+		kafka.ErrTimedOut,
+		kafka.ErrMsgTimedOut,
+		kafka.ErrResolve,
+		kafka.ErrLeaderNotAvailable,
+		kafka.ErrNotLeaderForPartition,
+		kafka.ErrRequestTimedOut,
+		kafka.ErrNotEnoughReplicas:
+		return true
+	}
+
+	return false
+}
+
+// produce publishes the message to the given cluster.
+func (p producer) produce(options ProduceOptions, cluster string) *Result {
+	instance, err := getDurableProducer(options.Context, cluster, options.Durability)
 	if err != nil {
 		return &Result{
 			Message: "Could not retrieve Kafka instance.",
@@ -55,67 +165,123 @@ func (p producer) Produce(options ProduceOptions) *Result {
 		}
 	} else if strings.Contains(strings.ToLower(md.Topics[options.Topic].Error.String()), "unknown") {
 		return &Result{
-			Error: errors.New(md.Topics[options.Topic].Error.String()),
+			Error: newTopicNotFoundError(options.Topic, md.Topics[options.Topic].Error),
+		}
+	} else if md.Topics[options.Topic].Error.Code() == kafka.ErrTopicAuthorizationFailed {
+		return &Result{
+			Error: md.Topics[options.Topic].Error,
+		}
+	}
+
+	msg, result := newMessage(options.Topic, options.Key, options.Data)
+	if result != nil {
+		return result
+	}
+
+	if re := checkMessageBytes(cluster, msg); re != nil {
+		return &Result{Error: re}
+	}
+
+	for _, name := range sortedKeys(options.Headers) {
+		msg.Headers = append(msg.Headers, kafka.Header{
+			Key:   name,
+			Value: []byte(options.Headers[name]),
+		})
+	}
+
+	// note the originally requested cluster when failing over
+	if !strings.EqualFold(cluster, options.Cluster) {
+		msg.Headers = append(msg.Headers, kafka.Header{
+			Key:   failoverHeader,
+			Value: []byte(options.Cluster),
+		})
+	}
+
+	if !inFlight.acquire(cluster) {
+		return &Result{
+			Message: "Too many messages in flight.",
+			Error:   errProducerBusy,
 		}
 	}
+	defer inFlight.release(cluster)
 
-	// used to notify when complete
-	done := make(chan *Result)
-
-	defer close(done)
-
-	// event watcher
-	go func() {
-		for e := range instance.Events() {
-			switch ev := e.(type) {
-			case *kafka.Message:
-				done <- &Result{
-					Message: fmt.Sprintf("%v", ev.TopicPartition),
-					Error:   ev.TopicPartition.Error,
-				}
-				return
-			case *kafka.Error:
-				if ev.IsFatal() {
-					done <- &Result{
-						Error: fmt.Errorf("fatal: %s", ev.Error()),
-					}
-					return
-				}
-			default:
-				if Config.Debug {
-					options.Log.Debug().Msgf("event: %+v", ev)
-				}
+	// used to notify when delivered, buffered so the delivery report never blocks.
+	deliveries := make(chan kafka.Event, 1)
+
+	// send the message without blocking when the local queue is full
+	if err := instance.Produce(msg, deliveries); err != nil {
+		var ke kafka.Error
+		if errors.As(err, &ke) && ke.Code() == kafka.ErrQueueFull {
+			return &Result{
+				Message: "Producer queue is full.",
+				Error:   fmt.Errorf("%w: %v", errProducerBusy, err),
 			}
 		}
-	}()
 
+		return &Result{Error: err}
+	}
+
+	select {
+	case e := <-deliveries:
+		m, ok := e.(*kafka.Message)
+		if !ok {
+			return &Result{Error: fmt.Errorf("unexpected delivery event: %v", e)}
+		}
+
+		return &Result{
+			Message:   fmt.Sprintf("%v", m.TopicPartition),
+			Partition: m.TopicPartition.Partition,
+			Offset:    int64(m.TopicPartition.Offset),
+			Timestamp: m.Timestamp,
+			KeySize:   len(msg.Key),
+			ValueSize: len(msg.Value),
+			Error:     m.TopicPartition.Error,
+		}
+	case <-options.Context.Done():
+		return &Result{
+			Message: "Message was enqueued, but the delivery report was not received in time.",
+			Error:   options.Context.Err(),
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// newMessage serializes the key and data into a message for the topic.
+func newMessage(topic string, key interface{}, data map[string]interface{}) (*kafka.Message, *Result) {
 	// parse key to byte
-	key, err := json.Marshal(options.Key)
+	k, err := json.Marshal(key)
 	if err != nil {
-		return &Result{
+		return nil, &Result{
 			Message: "Could not parse 'key' field",
 			Error:   err,
 		}
 	}
 
 	// parse data to byte
-	value, err := json.Marshal(options.Data)
+	value, err := json.Marshal(data)
 	if err != nil {
-		return &Result{
+		return nil, &Result{
 			Message: "Could not parse 'data' field",
 			Error:   err,
 		}
 	}
 
-	// send the message
-	instance.ProduceChannel() <- &kafka.Message{
+	return &kafka.Message{
 		TopicPartition: kafka.TopicPartition{
-			Topic:     &options.Topic,
+			Topic:     &topic,
 			Partition: int32(kafka.PartitionAny),
 		},
-		Key:   key,
+		Key:   k,
 		Value: value,
-	}
-
-	return <-done
+	}, nil
 }
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailoverClusters(t *testing.T) {
+	Config.KafkaFailover = map[string][]string{
+		"kafka-cl01": {"kafka-cl02", "kafka-cl03"},
+	}
+	defer func() {
+		Config.KafkaFailover = nil
+	}()
+
+	assert.Equal(t, []string{"kafka-cl01", "kafka-cl02", "kafka-cl03"}, failoverClusters("kafka-cl01"))
+	assert.Equal(t, []string{"kafka-cl02"}, failoverClusters("kafka-cl02"))
+}
+
+func TestIsRetriableBrokerError(t *testing.T) {
+	assert.True(t, isRetriableBrokerError(kafka.NewError(kafka.ErrAllBrokersDown, "down", false)))
+	assert.True(t, isRetriableBrokerError(kafka.NewError(kafka.ErrMsgTimedOut, "timed out", false)))
+	assert.False(t, isRetriableBrokerError(kafka.NewError(kafka.ErrUnknownTopicOrPart, "unknown", false)))
+	assert.False(t, isRetriableBrokerError(errors.New("not a kafka error")))
+}
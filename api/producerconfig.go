@@ -0,0 +1,150 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// allowedProducerConfig are the librdkafka producer properties that may be tuned per cluster in app-config.json.
+var allowedProducerConfig = map[string]bool{
+	"acks":                                  true,
+	"request.required.acks":                 true,
+	"linger.ms":                             true,
+	"queue.buffering.max.ms":                true,
+	"batch.size":                            true,
+	"batch.num.messages":                    true,
+	"compression.type":                      true,
+	"compression.codec":                     true,
+	"compression.level":                     true,
+	"message.timeout.ms":                    true,
+	"delivery.timeout.ms":                   true,
+	"request.timeout.ms":                    true,
+	"queue.buffering.max.messages":          true,
+	"queue.buffering.max.kbytes":            true,
+	"message.max.bytes":                     true,
+	"message.send.max.retries":              true,
+	"retries":                               true,
+	"retry.backoff.ms":                      true,
+	"max.in.flight.requests.per.connection": true,
+	"max.in.flight":                         true,
+	"partitioner":                           true,
+	"sticky.partitioning.linger.ms":         true,
+	"client.id":                             true,
+	"client.rack":                           true,
+	"socket.timeout.ms":                     true,
+	"socket.keepalive.enable":               true,
+	"connections.max.idle.ms":               true,
+	"reconnect.backoff.ms":                  true,
+	"reconnect.backoff.max.ms":              true,
+	"metadata.max.age.ms":                   true,
+	"topic.metadata.refresh.interval.ms":    true,
+	"statistics.interval.ms":                true,
+	"broker.address.family":                 true,
+	"enable.gapless.guarantee":              true,
+}
+
+// forbiddenProducerConfig are property prefixes that are derived from the secrets or managed by the proxy.
+var forbiddenProducerConfig = []string{
+	"bootstrap.servers",
+	"metadata.broker.list",
+	"security.protocol",
+	"sasl.",
+	"ssl.",
+	"enable.idempotence",
+	"transactional.id",
+	"debug",
+	"go.",
+}
+
+// validateProducerConfig ensures every cluster and property of producerConfig is known and allowed.
+func validateProducerConfig(c *appConfig) error {
+	problems := []string{}
+
+	for cluster, props := range c.ProducerConfig {
+		if !contains(c.KafkaBrokerGroups, cluster) {
+			problems = append(problems, fmt.Sprintf("producerConfig cluster '%s' is not in kafkaBrokerGroups", cluster))
+		}
+
+		for key, value := range props {
+			if err := validateProducerConfigProperty(key, value); err != nil {
+				problems = append(problems, fmt.Sprintf("producerConfig[%s]: %s", cluster, err.Error()))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid producerConfig:\n%s", strings.Join(problems, "\n"))
+	}
+
+	return nil
+}
+
+func validateProducerConfigProperty(key string, value interface{}) error {
+	for _, prefix := range forbiddenProducerConfig {
+		if strings.HasPrefix(key, prefix) {
+			return fmt.Errorf("'%s' is not allowed, it is managed by the proxy or secrets", key)
+		}
+	}
+
+	if !allowedProducerConfig[key] {
+		return fmt.Errorf("'%s' is not a supported producer property", key)
+	}
+
+	_, err := producerConfigValue(value)
+	if err != nil {
+		return fmt.Errorf("'%s': %s", key, err.Error())
+	}
+
+	return nil
+}
+
+// producerConfigValue converts a JSON value to a value librdkafka accepts.
+func producerConfigValue(value interface{}) (kafka.ConfigValue, error) {
+	switch v := value.(type) {
+	case bool, string:
+		return v, nil
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+
+	return nil, fmt.Errorf("unsupported value %v, must be a string, number or boolean", value)
+}
+
+// applyProducerConfig merges the cluster's producerConfig into the config map.
+func applyProducerConfig(cluster string, kcm kafka.ConfigMap) error {
+	for name, props := range Config.ProducerConfig {
+		if !strings.EqualFold(name, cluster) {
+			continue
+		}
+
+		for key, value := range props {
+			cv, err := producerConfigValue(value)
+			if err != nil {
+				return err
+			}
+
+			kcm[key] = cv
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
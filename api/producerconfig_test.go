@@ -0,0 +1,53 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateProducerConfigProperty(t *testing.T) {
+	tests := []struct {
+		key   string
+		value interface{}
+		valid bool
+	}{
+		{"linger.ms", float64(5), true},
+		{"compression.type", "lz4", true},
+		{"acks", "all", true},
+		{"socket.keepalive.enable", true, true},
+		{"bootstrap.servers", "broker:9092", false},
+		{"sasl.password", "secret", false},
+		{"ssl.key.pem", "key", false},
+		{"lingerms", float64(5), false},
+		{"batch.size", []interface{}{1}, false},
+	}
+
+	for _, test := range tests {
+		err := validateProducerConfigProperty(test.key, test.value)
+		assert.Equal(t, test.valid, err == nil, test.key)
+	}
+}
+
+func TestApplyProducerConfig(t *testing.T) {
+	setup()
+	Config.ProducerConfig = map[string]map[string]interface{}{
+		"kafka-cl01": {
+			"linger.ms":        float64(5),
+			"compression.type": "lz4",
+		},
+		"kafka-cl99": {
+			"acks": "all",
+		},
+	}
+	defer func() {
+		Config.ProducerConfig = nil
+	}()
+
+	assert.NotNil(t, validateProducerConfig(&Config))
+
+	kcm := kafka.ConfigMap{}
+	assert.Nil(t, applyProducerConfig("kafka-cl01", kcm))
+	assert.Equal(t, kafka.ConfigMap{"linger.ms": 5, "compression.type": "lz4"}, kcm)
+}
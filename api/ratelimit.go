@@ -0,0 +1,379 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	rateLimitWildcard    = "*"
+	rateLimitIdleTimeout = time.Minute * 10
+)
+
+// rateLimiter is the limiter shared by every publish path.
+var rateLimiter = newLimiter()
+
+func init() {
+	expvar.Publish("rateLimits", expvar.Func(func() interface{} {
+		return rateLimiter.Usage()
+	}))
+}
+
+// allowEvent charges the event to the rate limits of the principal, returning a 429 error when
+// they are exceeded.
+func allowEvent(principal, cluster, topic string, data map[string]interface{}) error {
+	if ok, wait := rateLimiter.Allow(principal, cluster, topic, dataSize(data)); !ok {
+		return newRateLimitedError(wait)
+	}
+
+	return nil
+}
+
+// allowTransaction charges every event of the transaction to the rate limits of the principal,
+// either all of them or none.
+func allowTransaction(principal, cluster string, events []TransactionEvent) error {
+	limited := make([]rateLimitedEvent, 0, len(events))
+	for _, e := range events {
+		limited = append(limited, rateLimitedEvent{e.Topic, dataSize(e.Data)})
+	}
+
+	if ok, wait := rateLimiter.AllowAll(principal, cluster, limited); !ok {
+		return newRateLimitedError(wait)
+	}
+
+	return nil
+}
+
+func dataSize(data map[string]interface{}) int {
+	if b, err := json.Marshal(data); err == nil {
+		return len(b)
+	}
+
+	return 0
+}
+
+func newRateLimitedError(wait time.Duration) *apiError {
+	return &apiError{
+		Status:     http.StatusTooManyRequests,
+		Code:       codeRateLimited,
+		Message:    "rate limit exceeded",
+		Retriable:  true,
+		RetryAfter: int(math.Ceil(wait.Seconds())),
+	}
+}
+
+// rateLimitRule limits the messages and bytes per second of each principal, cluster and topic it matches.
+// An empty or "*" field matches everything, the first matching rule applies.
+type rateLimitRule struct {
+	Principal         string  `json:"principal,omitempty"`
+	Cluster           string  `json:"cluster,omitempty"`
+	Topic             string  `json:"topic,omitempty"`
+	MessagesPerSecond float64 `json:"messagesPerSecond,omitempty"`
+	MessagesBurst     float64 `json:"messagesBurst,omitempty"`
+	BytesPerSecond    float64 `json:"bytesPerSecond,omitempty"`
+	BytesBurst        float64 `json:"bytesBurst,omitempty"`
+}
+
+func (rule rateLimitRule) matches(principal, cluster, topic string) bool {
+	match := func(pattern, value string) bool {
+		return len(pattern) == 0 || pattern == rateLimitWildcard || strings.EqualFold(pattern, value)
+	}
+
+	return match(rule.Principal, principal) && match(rule.Cluster, cluster) && match(rule.Topic, topic)
+}
+
+// tokenBucket refills at rate tokens per second up to burst, a zero rate is unlimited.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	if burst <= 0 {
+		burst = math.Max(rate, 1)
+	}
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait returns how long until n tokens are available, requests larger than the burst
+// only need a full bucket.
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+
+	n = math.Min(n, b.burst)
+	if b.tokens >= n {
+		return 0
+	}
+
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// exceeds returns true if n tokens are more than the bucket holds.
+func (b *tokenBucket) exceeds(n float64) bool {
+	return b.rate > 0 && n > b.burst
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate > 0 {
+		b.tokens -= math.Min(n, b.burst)
+	}
+}
+
+// validate rejects negative rates and bursts.
+func (rule rateLimitRule) validate() error {
+	for name, value := range map[string]float64{
+		"messagesPerSecond": rule.MessagesPerSecond,
+		"messagesBurst":     rule.MessagesBurst,
+		"bytesPerSecond":    rule.BytesPerSecond,
+		"bytesBurst":        rule.BytesBurst,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s %v must not be negative", name, value)
+		}
+	}
+
+	return nil
+}
+
+// rateLimitedEvent is a message charged to the rate limits.
+type rateLimitedEvent struct {
+	topic string
+	size  int
+}
+
+// limiterCharge is the messages and bytes taken from a pair of buckets.
+type limiterCharge struct {
+	messages float64
+	bytes    float64
+}
+
+type limiterBuckets struct {
+	// principal, cluster and topic are the values the rule was matched with.
+	principal string
+	cluster   string
+	topic     string
+	rule      rateLimitRule
+	messages  *tokenBucket
+	bytes     *tokenBucket
+	allowed   int64
+	rejected  int64
+}
+
+type rateLimitUsage struct {
+	Messages float64 `json:"messagesAvailable"`
+	Bytes    float64 `json:"bytesAvailable"`
+	Allowed  int64   `json:"allowed"`
+	Rejected int64   `json:"rejected"`
+}
+
+// limiter holds a pair of token buckets per principal, cluster and topic.
+type limiter struct {
+	mu        sync.Mutex
+	rules     []rateLimitRule
+	buckets   map[string]*limiterBuckets
+	lastSweep time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		buckets: map[string]*limiterBuckets{},
+	}
+}
+
+// SetRules replaces the rules. Buckets are kept when the rule that applies to them is unchanged,
+// so a reload doesn't refill every bucket.
+func (l *limiter) SetRules(rules []rateLimitRule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rules = rules
+
+	for key, lb := range l.buckets {
+		if rule := l.match(lb.principal, lb.cluster, lb.topic); rule == nil || *rule != lb.rule {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// match returns the first rule matching, nil if the message is unlimited.
+func (l *limiter) match(principal, cluster, topic string) *rateLimitRule {
+	for i := range l.rules {
+		if l.rules[i].matches(principal, cluster, topic) {
+			return &l.rules[i]
+		}
+	}
+
+	return nil
+}
+
+// Allow takes a message of size bytes from the buckets, returning false and how long to wait
+// when either bucket doesn't have enough tokens.
+func (l *limiter) Allow(principal, cluster, topic string, size int) (bool, time.Duration) {
+	return l.AllowAll(principal, cluster, []rateLimitedEvent{{topic, size}})
+}
+
+// AllowAll takes every message from the buckets, or none of them when a bucket doesn't have enough
+// tokens for its messages, returning false and how long to wait.
+func (l *limiter) AllowAll(principal, cluster string, events []rateLimitedEvent) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	charges := map[*limiterBuckets]*limiterCharge{}
+
+	for _, e := range events {
+		rule := l.match(principal, cluster, e.topic)
+		if rule == nil {
+			continue
+		}
+
+		key := strings.Join([]string{principal, strings.ToLower(cluster), e.topic}, "|")
+		lb, ok := l.buckets[key]
+		if !ok {
+			lb = &limiterBuckets{
+				principal: principal,
+				cluster:   cluster,
+				topic:     e.topic,
+				rule:      *rule,
+				messages:  newTokenBucket(rule.MessagesPerSecond, rule.MessagesBurst, now),
+				bytes:     newTokenBucket(rule.BytesPerSecond, rule.BytesBurst, now),
+			}
+			l.buckets[key] = lb
+		}
+
+		c, ok := charges[lb]
+		if !ok {
+			c = &limiterCharge{}
+			charges[lb] = c
+		}
+
+		c.messages++
+		c.bytes += float64(e.size)
+	}
+
+	var wait time.Duration
+
+	for lb, c := range charges {
+		lb.messages.refill(now)
+		lb.bytes.refill(now)
+
+		// unlike a large message, more messages than the burst are never allowed at once
+		if lb.messages.exceeds(c.messages) {
+			return l.reject(charges, time.Duration(lb.messages.burst/lb.messages.rate*float64(time.Second)))
+		}
+
+		if mw := lb.messages.wait(c.messages); mw > wait {
+			wait = mw
+		}
+		if bw := lb.bytes.wait(c.bytes); bw > wait {
+			wait = bw
+		}
+	}
+
+	if wait > 0 {
+		return l.reject(charges, wait)
+	}
+
+	for lb, c := range charges {
+		lb.messages.take(c.messages)
+		lb.bytes.take(c.bytes)
+		lb.allowed += int64(c.messages)
+	}
+
+	return true, 0
+}
+
+func (l *limiter) reject(charges map[*limiterBuckets]*limiterCharge, wait time.Duration) (bool, time.Duration) {
+	for lb, c := range charges {
+		lb.rejected += int64(c.messages)
+	}
+
+	return false, wait
+}
+
+// sweep removes the buckets that have been idle, keeping the map bounded.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+
+	for key, lb := range l.buckets {
+		if now.Sub(lb.messages.last) > rateLimitIdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+// Usage returns the current state of every bucket.
+func (l *limiter) Usage() map[string]rateLimitUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	usage := map[string]rateLimitUsage{}
+
+	now := time.Now()
+	for key, lb := range l.buckets {
+		lb.messages.refill(now)
+		lb.bytes.refill(now)
+
+		usage[key] = rateLimitUsage{
+			Messages: lb.messages.tokens,
+			Bytes:    lb.bytes.tokens,
+			Allowed:  lb.allowed,
+			Rejected: lb.rejected,
+		}
+	}
+
+	return usage
+}
+
+// SetRateLimits reloads the rateLimits section of the app-config.json, used to update the limits
+// without a restart. The current limits are kept if the file has unknown fields or invalid rules.
+func SetRateLimits(data []byte) {
+	var cfg appConfig
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&cfg); err != nil {
+		log.Err(err).Msg("invalid app-config.json, rate limits not reloaded")
+		return
+	}
+
+	if errs := checkRateLimits(&cfg); len(errs) > 0 {
+		for _, err := range errs {
+			log.Err(err).Msg("invalid rate limits, not reloaded")
+		}
+		return
+	}
+
+	rateLimiter.SetRules(cfg.RateLimits)
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	l := newLimiter()
+	l.SetRules([]rateLimitRule{
+		{Topic: "unlimited"},
+		{Principal: "*", MessagesPerSecond: 1, MessagesBurst: 2, BytesPerSecond: 100},
+	})
+
+	for i := 0; i < 5; i++ {
+		ok, _ := l.Allow("client", "kafka-cl01", "unlimited", 1000)
+		assert.True(t, ok)
+	}
+
+	ok, _ := l.Allow("client", "kafka-cl01", "topic", 10)
+	assert.True(t, ok)
+	ok, _ = l.Allow("client", "kafka-cl01", "topic", 10)
+	assert.True(t, ok)
+
+	ok, wait := l.Allow("client", "kafka-cl01", "topic", 10)
+	assert.False(t, ok)
+	assert.True(t, wait > 0 && wait <= time.Second)
+
+	// buckets are per principal
+	ok, _ = l.Allow("other", "kafka-cl01", "topic", 10)
+	assert.True(t, ok)
+
+	usage := l.Usage()["client|kafka-cl01|topic"]
+	assert.Equal(t, int64(2), usage.Allowed)
+	assert.Equal(t, int64(1), usage.Rejected)
+}
+
+func TestSetRateLimits(t *testing.T) {
+	defer rateLimiter.SetRules(nil)
+
+	SetRateLimits([]byte(`{"serverPort": 39000, "rateLimits": [{"topic": "topic", "bytesPerSecond": 10}]}`))
+
+	ok, _ := rateLimiter.Allow("client", "kafka-cl01", "topic", 10)
+	assert.True(t, ok)
+	ok, _ = rateLimiter.Allow("client", "kafka-cl01", "topic", 10)
+	assert.False(t, ok)
+}
+
+func TestSetRateLimitsInvalid(t *testing.T) {
+	rateLimiter.SetRules([]rateLimitRule{{Topic: "topic", MessagesPerSecond: 1}})
+	defer rateLimiter.SetRules(nil)
+
+	for _, data := range []string{
+		`{"rateLimits": [{"topic": "topic", "messagesPerSecond": -1}]}`,
+		`{"rateLimits": [{"topic": "topic", "messagesPerScond": 10}]}`,
+		`{"kafkaBrokerGroups": ["kafka-cl01"], "rateLimits": [{"cluster": "kafka-cl02", "messagesPerSecond": 10}]}`,
+	} {
+		SetRateLimits([]byte(data))
+
+		assert.Equal(t, []rateLimitRule{{Topic: "topic", MessagesPerSecond: 1}}, rateLimiter.rules)
+	}
+}
+
+func TestLimiterSetRulesKeepsBuckets(t *testing.T) {
+	l := newLimiter()
+	l.SetRules([]rateLimitRule{
+		{Topic: "orders", MessagesPerSecond: 1},
+		{Topic: "payments", MessagesPerSecond: 1},
+	})
+
+	for _, topic := range []string{"orders", "payments"} {
+		ok, _ := l.Allow("client", "kafka-cl01", topic, 10)
+		assert.True(t, ok)
+	}
+
+	// only the bucket of the changed rule is refilled
+	l.SetRules([]rateLimitRule{
+		{Topic: "orders", MessagesPerSecond: 1},
+		{Topic: "payments", MessagesPerSecond: 2},
+	})
+
+	ok, _ := l.Allow("client", "kafka-cl01", "orders", 10)
+	assert.False(t, ok)
+	ok, _ = l.Allow("client", "kafka-cl01", "payments", 10)
+	assert.True(t, ok)
+}
+
+func TestLimiterAllowAll(t *testing.T) {
+	l := newLimiter()
+	l.SetRules([]rateLimitRule{{MessagesPerSecond: 1, MessagesBurst: 2}})
+
+	ok, _ := l.AllowAll("client", "kafka-cl01", []rateLimitedEvent{{"orders", 10}, {"orders", 10}, {"orders", 10}})
+	assert.False(t, ok)
+
+	// the rejected batch took no tokens
+	ok, _ = l.AllowAll("client", "kafka-cl01", []rateLimitedEvent{{"orders", 10}, {"orders", 10}})
+	assert.True(t, ok)
+}
+
+func TestPrincipalOf(t *testing.T) {
+	setup()
+
+	// unchecked tokens don't get their own buckets
+	assert.Equal(t, "192.0.2.1", principalOf("any", "192.0.2.1:1234"))
+
+	Config.EnableAPIAuth = true
+	defer func() {
+		Config.EnableAPIAuth = false
+	}()
+
+	assert.Equal(t, "192.0.2.1", principalOf("wrong", "192.0.2.1:1234"))
+	assert.True(t, strings.HasPrefix(principalOf("TestApiToken", "192.0.2.1:1234"), "token:"))
+}
+
+func TestPublishTransactionRateLimited(t *testing.T) {
+	setup()
+	defer rateLimiter.SetRules(nil)
+
+	rateLimiter.SetRules([]rateLimitRule{{MessagesPerSecond: 1, MessagesBurst: 2}})
+
+	cp := &countingProducer{}
+	rh := router{kp: cp}
+
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{"cluster": "kafka-cl01", "events": [
+		{"topic": "orders", "data": {"id": 1}},
+		{"topic": "orders", "data": {"id": 2}},
+		{"topic": "orders", "data": {"id": 3}}
+	]}`))
+	w := httptest.NewRecorder()
+	rh.PublishTransaction(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Result().StatusCode)
+	assert.Equal(t, 0, cp.count)
+}
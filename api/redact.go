@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+)
+
+const redactedValue = "[REDACTED]"
+
+// logRedactor is the redactor for request payloads written to the logs.
+var logRedactor = &redactor{}
+
+// redactor replaces the values matching its paths before a document is logged.
+type redactor struct {
+	paths []jsonPath
+}
+
+// newRedactor parses the JSONPath patterns of the values to redact.
+func newRedactor(patterns []string) (*redactor, error) {
+	r := &redactor{}
+
+	for _, pattern := range patterns {
+		p, err := parseJSONPath(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		r.paths = append(r.paths, p)
+	}
+
+	return r, nil
+}
+
+// Redact returns the JSON of v with the matching values replaced, v itself is not modified.
+func (r *redactor) Redact(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return redactedValue
+	}
+
+	if len(r.paths) == 0 {
+		return string(b)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return redactedValue
+	}
+
+	for _, p := range r.paths {
+		p.Replace(doc, func(interface{}) interface{} {
+			return redactedValue
+		})
+	}
+
+	b, _ = json.Marshal(doc)
+
+	return string(b)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	r, err := newRedactor([]string{"$.data.ssn", "$.data.cards[*].number", "$.key"})
+	assert.Nil(t, err)
+
+	er := EventRequest{
+		Cluster: "kafka-cl01",
+		Topic:   "customers",
+		Key:     "123-45-6789",
+		Data: map[string]interface{}{
+			"name": "jane",
+			"ssn":  "123-45-6789",
+			"cards": []interface{}{
+				map[string]interface{}{"number": "4111111111111111", "type": "visa"},
+			},
+		},
+	}
+
+	assert.JSONEq(t, `{
+		"cluster": "kafka-cl01",
+		"topic": "customers",
+		"key": "[REDACTED]",
+		"data": {
+			"name": "jane",
+			"ssn": "[REDACTED]",
+			"cards": [{"number": "[REDACTED]", "type": "visa"}]
+		}
+	}`, r.Redact(er))
+
+	// the request itself is left untouched
+	assert.Equal(t, "123-45-6789", er.Data["ssn"])
+
+	_, err = newRedactor([]string{"data.ssn"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,355 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+)
+
+// Routes compatible with the produce API of Confluent REST Proxy, so clients can switch without
+// being rewritten. Only JSON embedded formats are supported.
+const (
+	restProxyV2ContentType = "application/vnd.kafka.v2+json"
+	restProxyV2JSON        = "application/vnd.kafka.json.v2+json"
+	restProxyJSONType      = "JSON"
+	restProxyStringType    = "STRING"
+
+	// per record error codes of the v2 offsets
+	restProxyNonRetriable = 1
+	restProxyRetriable    = 2
+)
+
+// restProxyConfig configures the REST Proxy compatible routes.
+type restProxyConfig struct {
+	// Cluster is used by the v2 routes, which don't include a cluster. Defaults to the first of kafkaBrokerGroups.
+	Cluster string `json:"cluster,omitempty"`
+}
+
+type restProxyV2Record struct {
+	Key interface{} `json:"key"`
+	// Value is any JSON so non-object values get a clear error, only objects can be produced.
+	Value     interface{} `json:"value"`
+	Partition *int32      `json:"partition,omitempty"`
+}
+
+type restProxyV2Request struct {
+	Records []restProxyV2Record `json:"records"`
+	// accepted for compatibility, the json format has no schemas
+	KeySchema     json.RawMessage `json:"key_schema,omitempty"`
+	KeySchemaID   json.RawMessage `json:"key_schema_id,omitempty"`
+	ValueSchema   json.RawMessage `json:"value_schema,omitempty"`
+	ValueSchemaID json.RawMessage `json:"value_schema_id,omitempty"`
+}
+
+type restProxyV2Offset struct {
+	Partition *int32  `json:"partition"`
+	Offset    *int64  `json:"offset"`
+	ErrorCode *int    `json:"error_code"`
+	Error     *string `json:"error"`
+}
+
+type restProxyV2Response struct {
+	KeySchemaID   *int                `json:"key_schema_id"`
+	ValueSchemaID *int                `json:"value_schema_id"`
+	Offsets       []restProxyV2Offset `json:"offsets"`
+}
+
+type restProxyV3Data struct {
+	Type string      `json:"type,omitempty"`
+	Data interface{} `json:"data"`
+}
+
+type restProxyV3Header struct {
+	Name string `json:"name"`
+	// Value is base64 encoded
+	Value []byte `json:"value"`
+}
+
+type restProxyV3Request struct {
+	PartitionID *int32              `json:"partition_id,omitempty"`
+	Headers     []restProxyV3Header `json:"headers,omitempty"`
+	Key         *restProxyV3Data    `json:"key,omitempty"`
+	Value       *restProxyV3Data    `json:"value,omitempty"`
+	Timestamp   *time.Time          `json:"timestamp,omitempty"`
+}
+
+type restProxyV3Size struct {
+	Type string `json:"type"`
+	Size int    `json:"size"`
+}
+
+type restProxyV3Response struct {
+	ErrorCode   int              `json:"error_code"`
+	ClusterID   string           `json:"cluster_id"`
+	TopicName   string           `json:"topic_name"`
+	PartitionID int32            `json:"partition_id"`
+	Offset      int64            `json:"offset"`
+	Timestamp   *time.Time       `json:"timestamp,omitempty"`
+	Key         *restProxyV3Size `json:"key,omitempty"`
+	Value       *restProxyV3Size `json:"value,omitempty"`
+}
+
+type restProxyError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// restProxyCluster returns the cluster of the v2 routes.
+func restProxyCluster() string {
+	if len(Config.RestProxy.Cluster) > 0 || len(Config.KafkaBrokerGroups) == 0 {
+		return Config.RestProxy.Cluster
+	}
+
+	return Config.KafkaBrokerGroups[0]
+}
+
+// RestProxyV2Produce produces the records to the topic like `POST /topics/{topic}` of REST Proxy v2.
+func (rh router) RestProxyV2Produce(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	topic := mux.Vars(r)["topic"]
+
+	w.Header().Set("Content-Type", restProxyV2ContentType)
+
+	if err := checkAPIToken(r.Header.Get("X-API-TOKEN"), r.RemoteAddr); err != nil {
+		writeRestProxyError(w, r, err, true)
+		return
+	}
+
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt != restProxyV2JSON && mt != "application/json" {
+		writeRestProxyError(w, r, &apiError{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    codeUnsupportedMedia,
+			Message: fmt.Sprintf("only %s is supported", restProxyV2JSON),
+		}, true)
+		return
+	}
+
+	var req restProxyV2Request
+	if re := decodeBody(w, r, &req); re != nil {
+		writeRestProxyError(w, r, re, true)
+		return
+	}
+
+	if len(req.Records) == 0 {
+		writeRestProxyError(w, r, validationError([]fieldError{{"records", fieldCodeRequired, "at least one record is required"}}), true)
+		return
+	}
+
+	resp := restProxyV2Response{Offsets: make([]restProxyV2Offset, 0, len(req.Records))}
+	events := make([]EventRequest, 0, len(req.Records))
+
+	for i, record := range req.Records {
+		if record.Partition != nil {
+			writeRestProxyError(w, r, validationError([]fieldError{{fmt.Sprintf("records[%d].partition", i), "unsupported", "partition can't be set"}}), true)
+			return
+		}
+
+		// strings, numbers, arrays and null (tombstones) can't be produced as the data of an event
+		data, ok := record.Value.(map[string]interface{})
+		if !ok {
+			writeRestProxyError(w, r, &apiError{
+				Status:  http.StatusUnprocessableEntity,
+				Code:    codeValidationFailed,
+				Message: "record value must be a JSON object",
+				Fields:  []fieldError{{fmt.Sprintf("records[%d].value", i), "invalid_type", "only JSON object values are supported"}},
+			}, true)
+			return
+		}
+
+		er := EventRequest{Cluster: restProxyCluster(), Topic: topic, Key: record.Key, Data: data}
+		if re := validateEventRequest(er); re != nil {
+			writeRestProxyError(w, r, re, true)
+			return
+		}
+
+		events = append(events, er)
+	}
+
+	for _, er := range events {
+		result, err := rh.produceEvent(r.Context(), log, principal(r), er)
+		if err != nil {
+			ae := classifyError(err)
+			log.Warn().Err(err).Msg("rest proxy record failed")
+
+			// the topic not existing fails the whole request, like REST Proxy
+			if ae.Code == codeTopicNotFound {
+				writeRestProxyError(w, r, ae, true)
+				return
+			}
+
+			code := restProxyNonRetriable
+			if ae.Retriable {
+				code = restProxyRetriable
+			}
+
+			resp.Offsets = append(resp.Offsets, restProxyV2Offset{ErrorCode: &code, Error: &ae.Message})
+			continue
+		}
+
+		offset := restProxyV2Offset{}
+		if !result.Spooled {
+			offset.Partition, offset.Offset = &result.Partition, &result.Offset
+		}
+
+		resp.Offsets = append(resp.Offsets, offset)
+	}
+
+	b, _ := json.Marshal(resp)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// RestProxyV3Produce produces a single record like `POST /v3/clusters/{cluster}/topics/{topic}/records`
+// of REST Proxy v3.
+func (rh router) RestProxyV3Produce(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	vars := mux.Vars(r)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := checkAPIToken(r.Header.Get("X-API-TOKEN"), r.RemoteAddr); err != nil {
+		writeRestProxyError(w, r, err, false)
+		return
+	}
+
+	var req restProxyV3Request
+	if re := decodeBody(w, r, &req); re != nil {
+		writeRestProxyError(w, r, re, false)
+		return
+	}
+
+	// the size is checked once the transforms and key rules were applied
+	er, re := restProxyV3Event(vars["cluster"], vars["topic"], req)
+	if re == nil {
+		re = validateEventFields(er)
+	}
+
+	if re != nil {
+		writeRestProxyError(w, r, re, false)
+		return
+	}
+
+	result, err := rh.produceEvent(r.Context(), log, principal(r), er)
+	if err != nil {
+		writeRestProxyError(w, r, err, false)
+		return
+	}
+
+	resp := restProxyV3Response{
+		ErrorCode:   http.StatusOK,
+		ClusterID:   result.Cluster,
+		TopicName:   er.Topic,
+		PartitionID: result.Partition,
+		Offset:      result.Offset,
+	}
+
+	if !result.Timestamp.IsZero() {
+		resp.Timestamp = &result.Timestamp
+	}
+
+	if result.ValueSize > 0 {
+		resp.Key = &restProxyV3Size{restProxyJSONType, result.KeySize}
+		resp.Value = &restProxyV3Size{restProxyJSONType, result.ValueSize}
+	}
+
+	status := http.StatusOK
+	if result.Spooled {
+		resp.ErrorCode = http.StatusAccepted
+		resp.ClusterID = er.Cluster
+		status = http.StatusAccepted
+	}
+
+	b, _ := json.Marshal(resp)
+
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// restProxyV3Event maps the record to an event, the key may be JSON or a string and the value
+// must be a JSON object.
+func restProxyV3Event(cluster, topic string, req restProxyV3Request) (EventRequest, *apiError) {
+	er := EventRequest{Cluster: cluster, Topic: topic}
+	fields := []fieldError{}
+
+	if req.PartitionID != nil {
+		fields = append(fields, fieldError{"partition_id", "unsupported", "partition_id can't be set"})
+	}
+
+	if req.Key != nil {
+		switch strings.ToUpper(req.Key.Type) {
+		case "", restProxyJSONType, restProxyStringType:
+			er.Key = req.Key.Data
+		default:
+			fields = append(fields, fieldError{"key.type", "unsupported", "key type must be JSON or STRING"})
+		}
+	}
+
+	if req.Value == nil {
+		fields = append(fields, fieldError{"value", fieldCodeRequired, "value is required"})
+	} else if t := strings.ToUpper(req.Value.Type); t != "" && t != restProxyJSONType {
+		fields = append(fields, fieldError{"value.type", "unsupported", "value type must be JSON"})
+	} else if data, ok := req.Value.Data.(map[string]interface{}); ok {
+		er.Data = data
+	} else {
+		fields = append(fields, fieldError{"value.data", "invalid_type", "value data must be a JSON object"})
+	}
+
+	for i, h := range req.Headers {
+		if len(h.Name) == 0 {
+			fields = append(fields, fieldError{fmt.Sprintf("headers[%d].name", i), fieldCodeRequired, "header name is required"})
+			continue
+		}
+
+		if er.Headers == nil {
+			er.Headers = map[string]string{}
+		}
+
+		er.Headers[h.Name] = string(h.Value)
+	}
+
+	if len(fields) > 0 {
+		return er, validationError(fields)
+	}
+
+	return er, nil
+}
+
+// writeRestProxyError writes the error in the REST Proxy shape, v2 error codes extend the
+// status with two digits, e.g. 40401.
+func writeRestProxyError(w http.ResponseWriter, r *http.Request, err error, v2 bool) {
+	ae := classifyError(err)
+	log := hlog.FromRequest(r)
+
+	if ae.Status >= http.StatusInternalServerError {
+		log.Error().Err(err).Msg("rest proxy request failed")
+	} else {
+		log.Warn().Err(err).Msg("rest proxy request failed")
+	}
+
+	message := ae.Message
+	if len(ae.Fields) > 0 {
+		message = fmt.Sprintf("%s: %s %s", message, ae.Fields[0].Field, ae.Fields[0].Message)
+	}
+
+	code := ae.Status
+	if v2 {
+		code = ae.Status*100 + 1
+	}
+
+	if ae.RetryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", ae.RetryAfter))
+	}
+
+	b, _ := json.Marshal(restProxyError{code, message})
+
+	w.WriteHeader(ae.Status)
+	w.Write(b)
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func restProxyRouter(kp kafkaProducer) *mux.Router {
+	mr := mux.NewRouter()
+	configureRouter(mr, kp)
+
+	return mr
+}
+
+func TestRestProxyV2Produce(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	mr := restProxyRouter(cp)
+
+	body := `{"records": [{"key": "k1", "value": {"id": 1}}, {"value": {"id": 2}}]}`
+	req := httptest.NewRequest("POST", "/topics/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	w := httptest.NewRecorder()
+
+	mr.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/vnd.kafka.v2+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"key_schema_id": null,
+		"value_schema_id": null,
+		"offsets": [
+			{"partition": 0, "offset": 0, "error_code": null, "error": null},
+			{"partition": 0, "offset": 0, "error_code": null, "error": null}
+		]
+	}`, w.Body.String())
+	assert.Equal(t, 2, cp.count)
+	assert.Equal(t, Config.KafkaBrokerGroups[0], cp.last.Cluster)
+	assert.Equal(t, "orders", cp.last.Topic)
+}
+
+func TestRestProxyV2ProduceErrors(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	mr := restProxyRouter(cp)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        int
+	}{
+		{"unsupported format", "application/vnd.kafka.avro.v2+json", `{"records": [{"value": {}}]}`, http.StatusUnsupportedMediaType, 41501},
+		{"no records", "application/vnd.kafka.json.v2+json", `{"records": []}`, http.StatusBadRequest, 40001},
+		{"partition", "application/vnd.kafka.json.v2+json", `{"records": [{"value": {}, "partition": 1}]}`, http.StatusBadRequest, 40001},
+		{"invalid json", "application/vnd.kafka.json.v2+json", `{"records": [`, http.StatusBadRequest, 40001},
+		{"string value", "application/vnd.kafka.json.v2+json", `{"records": [{"value": {}}, {"value": "text"}]}`, http.StatusUnprocessableEntity, 42201},
+		{"null value", "application/vnd.kafka.json.v2+json", `{"records": [{"key": "k", "value": null}]}`, http.StatusUnprocessableEntity, 42201},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/topics/orders", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			mr.ServeHTTP(w, req)
+
+			var resp restProxyError
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.code, resp.ErrorCode)
+		})
+	}
+
+	assert.Equal(t, 0, cp.count)
+}
+
+func TestRestProxyV3Produce(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	mr := restProxyRouter(cp)
+
+	body := `{
+		"headers": [{"name": "trace", "value": "YWJj"}],
+		"key": {"type": "STRING", "data": "k1"},
+		"value": {"type": "JSON", "data": {"id": 1}}
+	}`
+	req := httptest.NewRequest("POST", "/v3/clusters/kafka-cl01/topics/orders/records", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	mr.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"error_code": 200,
+		"cluster_id": "kafka-cl01",
+		"topic_name": "orders",
+		"partition_id": 0,
+		"offset": 0,
+		"key": {"type": "JSON", "size": 4},
+		"value": {"type": "JSON", "size": 8}
+	}`, w.Body.String())
+	assert.Equal(t, "k1", cp.last.Key)
+	assert.Equal(t, map[string]string{"trace": "abc"}, cp.last.Headers)
+}
+
+func TestRestProxyV3Event(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+	}{
+		{"json value", `{"value": {"data": {"id": 1}}}`, nil},
+		{"missing value", `{"key": {"data": "k"}}`, []string{"value"}},
+		{"binary value", `{"value": {"type": "BINARY", "data": "YWJj"}}`, []string{"value.type"}},
+		{"non object value", `{"value": {"type": "JSON", "data": [1, 2]}}`, []string{"value.data"}},
+		{"avro key", `{"key": {"type": "AVRO", "data": "k"}, "value": {"data": {}}}`, []string{"key.type"}},
+		{"partition", `{"partition_id": 1, "value": {"data": {}}}`, []string{"partition_id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req restProxyV3Request
+			assert.Nil(t, json.Unmarshal([]byte(tt.body), &req))
+
+			_, re := restProxyV3Event("kafka-cl01", "orders", req)
+			if tt.fields == nil {
+				assert.Nil(t, re)
+				return
+			}
+
+			fields := []string{}
+			for _, f := range re.Fields {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+// the size is checked on the message as produced, after the transforms.
+func TestRestProxyV3ProduceMessageSize(t *testing.T) {
+	setup()
+	Config.ProducerConfig = map[string]map[string]interface{}{
+		"kafka-cl01": {"message.max.bytes": float64(64)},
+	}
+	tp, err := newTransformPipelines(map[string][]transformStepConfig{
+		"orders":   {{Type: "removeField", Path: "$.blob"}},
+		"payments": {{Type: "addField", Path: "$.blob", Value: strings.Repeat("a", 64)}},
+	})
+	assert.Nil(t, err)
+	payloadTransforms = tp
+	defer func() {
+		Config.ProducerConfig = nil
+		payloadTransforms = transformPipelines{}
+	}()
+
+	cp := &countingProducer{}
+	mr := restProxyRouter(cp)
+
+	body := `{"value": {"data": {"id": 1, "blob": "` + strings.Repeat("a", 64) + `"}}}`
+	req := httptest.NewRequest("POST", "/v3/clusters/kafka-cl01/topics/orders/records", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	mr.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, cp.count)
+
+	_, err = spoolEvent(ProduceOptions{Cluster: "kafka-cl01", Topic: "payments", Data: map[string]interface{}{"id": 1}})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, classifyError(err).Status)
+}
@@ -2,13 +2,17 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
-	"strings"
+	"strconv"
 
-	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
@@ -18,8 +22,22 @@ import (
 type Router interface {
 	Ping(w http.ResponseWriter, r *http.Request)
 	Health(w http.ResponseWriter, r *http.Request)
+	GetOpenAPI(w http.ResponseWriter, r *http.Request)
 	PublishEvent(w http.ResponseWriter, r *http.Request)
+	PublishStream(w http.ResponseWriter, r *http.Request)
+	PublishWebSocket(w http.ResponseWriter, r *http.Request)
+	PublishTransaction(w http.ResponseWriter, r *http.Request)
 	GetAvailableClusters(w http.ResponseWriter, r *http.Request)
+	GetCluster(w http.ResponseWriter, r *http.Request)
+	GetTopics(w http.ResponseWriter, r *http.Request)
+	GetTopic(w http.ResponseWriter, r *http.Request)
+	CreateTopic(w http.ResponseWriter, r *http.Request)
+	CreatePartitions(w http.ResponseWriter, r *http.Request)
+	AlterTopicConfigs(w http.ResponseWriter, r *http.Request)
+	GetSpoolStats(w http.ResponseWriter, r *http.Request)
+	TailTopic(w http.ResponseWriter, r *http.Request)
+	RestProxyV2Produce(w http.ResponseWriter, r *http.Request)
+	RestProxyV3Produce(w http.ResponseWriter, r *http.Request)
 }
 
 type router struct {
@@ -32,8 +50,23 @@ func configureRouter(mr *mux.Router, kp kafkaProducer) {
 
 	mr.HandleFunc("/ping", r.Ping).Methods(http.MethodGet)
 	mr.HandleFunc("/health", r.Health).Methods(http.MethodGet)
+	mr.HandleFunc("/openapi.json", r.GetOpenAPI).Methods(http.MethodGet)
 	mr.HandleFunc("/events", r.PublishEvent).Methods(http.MethodPost, http.MethodDelete)
+	mr.HandleFunc("/events/stream", r.PublishStream).Methods(http.MethodPost)
+	mr.HandleFunc("/events/ws", r.PublishWebSocket).Methods(http.MethodGet)
+	mr.HandleFunc("/transactions", r.PublishTransaction).Methods(http.MethodPost)
 	mr.HandleFunc("/clusters", r.GetAvailableClusters).Methods(http.MethodGet)
+	mr.HandleFunc("/clusters/{cluster}", r.GetCluster).Methods(http.MethodGet)
+	mr.HandleFunc("/clusters/{cluster}/topics", r.GetTopics).Methods(http.MethodGet)
+	mr.HandleFunc("/clusters/{cluster}/topics/{topic}", r.GetTopic).Methods(http.MethodGet)
+	mr.HandleFunc("/clusters/{cluster}/topics/{topic}/tail", r.TailTopic).Methods(http.MethodGet)
+	mr.HandleFunc("/admin/spool", r.GetSpoolStats).Methods(http.MethodGet)
+	mr.HandleFunc("/admin/clusters/{cluster}/topics", r.CreateTopic).Methods(http.MethodPost)
+	mr.HandleFunc("/admin/clusters/{cluster}/topics/{topic}/partitions", r.CreatePartitions).Methods(http.MethodPost)
+	mr.HandleFunc("/admin/clusters/{cluster}/topics/{topic}/configs", r.AlterTopicConfigs).Methods(http.MethodPatch)
+	mr.HandleFunc("/debug/vars", r.DebugVars).Methods(http.MethodGet)
+	mr.HandleFunc("/topics/{topic}", r.RestProxyV2Produce).Methods(http.MethodPost)
+	mr.HandleFunc("/v3/clusters/{cluster}/topics/{topic}/records", r.RestProxyV3Produce).Methods(http.MethodPost)
 }
 
 // Health checks the health of the API. Should try
@@ -42,29 +75,18 @@ func (rh router) Health(w http.ResponseWriter, r *http.Request) {
 	errs := &bytes.Buffer{}
 
 	for _, cluster := range Config.KafkaBrokerGroups {
-		var p *kafka.Producer
-		var ac *kafka.AdminClient
-		var err error
-
-		p, err = getProducer(r.Context(), cluster)
+		_, err := checkClusterHealth(r.Context(), cluster)
 		if err != nil {
-			errs.WriteString(fmt.Sprintf("%s\n", err.Error()))
-		}
+			hlog.FromRequest(r).Error().Err(err).Msgf("cluster %s is unhealthy", cluster)
 
-		if p != nil {
-			ac, err = kafka.NewAdminClientFromProducer(p)
-			if err != nil {
+			if Config.ExposeErrorDetails {
 				errs.WriteString(fmt.Sprintf("%s\n", err.Error()))
+			} else {
+				errs.WriteString(fmt.Sprintf("cluster %s is unhealthy\n", cluster))
 			}
 		}
 
-		if ac != nil {
-			// Get a single or all topics, timeout of 15 seconds
-			_, err = ac.GetMetadata(Config.KafkaHealthTopic, (Config.KafkaHealthTopic == nil), 15000)
-			if err != nil {
-				errs.WriteString(fmt.Sprintf("%s\n", err.Error()))
-			}
-		}
+		clusterHealthChecker.set(cluster, err)
 	}
 
 	if errs.Len() > 0 {
@@ -84,9 +106,19 @@ func (rh router) Ping(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetAvailableClusters returns the list of available brokers defined in the app-config.json
+// the caller may see.
 func (rh router) GetAvailableClusters(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+
+	clusters := []string{}
+	for _, cluster := range Config.KafkaBrokerGroups {
+		if clusterVisible(p, cluster) {
+			clusters = append(clusters, cluster)
+		}
+	}
+
 	b, err := json.Marshal(map[string][]string{
-		"clusters": Config.KafkaBrokerGroups,
+		"clusters": clusters,
 	})
 
 	if err != nil {
@@ -99,46 +131,209 @@ func (rh router) GetAvailableClusters(w http.ResponseWriter, r *http.Request) {
 }
 
 type EventRequest struct {
-	Cluster string                 `json:"cluster"`
-	Topic   string                 `json:"topic"`
-	Key     interface{}            `json:"key"`
-	Data    map[string]interface{} `json:"data"`
+	Cluster    string                 `json:"cluster"`
+	Topic      string                 `json:"topic"`
+	Key        interface{}            `json:"key"`
+	Data       map[string]interface{} `json:"data"`
+	Durability string                 `json:"durability,omitempty"`
+	// Headers are added to the message, set from the CloudEvents binding.
+	Headers map[string]string `json:"-"`
 }
 
 type eventResponse struct {
 	Message string `json:"message,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
 }
 
 func (rh router) PublishEvent(w http.ResponseWriter, r *http.Request) {
 	log := hlog.FromRequest(r)
 
-	// Optional authentication via API token.
-	if Config.EnableAPIAuth {
-		apiToken := r.Header.Get("X-API-TOKEN")
-		if !strings.EqualFold(Secrets.APIToken, apiToken) {
-			writeErrorResponse(w, log, fmt.Sprintf("API Token Request Failed: RemoteAddress %s", r.RemoteAddr), nil)
+	if !authorized(w, r) {
+		return
+	}
+
+	var er EventRequest
+	var re *apiError
+
+	if isCloudEvent(r) {
+		er, re = decodeCloudEvent(w, r)
+	} else {
+		re = decodeBody(w, r, &er)
+	}
+
+	if re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	if re := validateEventRequest(er); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	log.Debug().Msg(fmt.Sprintf("%s: %s", r.Method, logRedactor.Redact(er)))
+
+	if key := r.Header.Get(idempotencyKeyHeader); len(key) > 0 && idempotencyKeys != nil {
+		rh.publishIdempotent(w, r, key, er)
+		return
+	}
+
+	rh.publish(w, r, er)
+}
+
+// publishIdempotent returns the original result for a repeated idempotency key, otherwise the
+// event is published and the result stored for the key.
+func (rh router) publishIdempotent(w http.ResponseWriter, r *http.Request, key string, er EventRequest) {
+	log := hlog.FromRequest(r)
+	hash := requestHash(er)
+	key = idempotencyCacheKey(principal(r), er.Cluster, key)
+
+	var entry *idempotencyEntry
+
+	for {
+		var existing bool
+		if entry, existing = idempotencyKeys.Begin(key, hash); !existing {
+			break
+		}
+
+		if entry.hash != hash {
+			writeErrorResponse(w, log, "", &apiError{
+				Status:  http.StatusConflict,
+				Code:    codeIdempotencyConflict,
+				Message: "idempotency key was already used with a different payload",
+			})
+			return
+		}
+
+		status, body, err := entry.Wait(r.Context())
+		if err != nil {
+			writeErrorResponse(w, log, "error waiting on the original request", err)
 			return
 		}
+
+		// the original request failed and was forgotten, try again
+		if status == 0 {
+			continue
+		}
+
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+
+	rr := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	rh.publish(rr, r, er)
+
+	if rr.status >= 200 && rr.status < 300 {
+		idempotencyKeys.Complete(entry, rr.status, rr.body)
+	} else {
+		idempotencyKeys.Forget(entry)
+	}
+}
+
+// publish produces the event, spooling it if the cluster is unavailable.
+func (rh router) publish(w http.ResponseWriter, r *http.Request, er EventRequest) {
+	log := hlog.FromRequest(r)
+
+	result, err := rh.produceEvent(r.Context(), log, principal(r), er)
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	b, _ := json.Marshal(eventResponse{result.Message, result.Cluster})
+
+	if result.Spooled {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Write([]byte(b))
+}
+
+// produceEvent applies the caller's rate limit and produces the event, spooling it if the
+// cluster is unavailable.
+func (rh router) produceEvent(ctx context.Context, log *zerolog.Logger, principal string, er EventRequest) (*Result, error) {
+	options := ProduceOptions{
+		Context:    ctx,
+		Log:        log,
+		Cluster:    er.Cluster,
+		Topic:      er.Topic,
+		Key:        er.Key,
+		Data:       er.Data,
+		Durability: er.Durability,
+		Principal:  principal,
+		Headers:    er.Headers,
+	}
+
+	if err := allowEvent(principal, er.Cluster, er.Topic, er.Data); err != nil {
+		return nil, err
+	}
+
+	// keep the order of messages by spooling while older messages are waiting to be replayed.
+	if messageSpool != nil && messageSpool.Pending(er.Cluster) {
+		return spoolEvent(options)
+	}
+
+	result := rh.kp.Produce(options)
+
+	if result.Error != nil {
+		if messageSpool != nil && isRetriableBrokerError(result.Error) {
+			log.Warn().Err(result.Error).Msg("cluster unavailable, spooling message")
+			return spoolEvent(options)
+		}
+
+		return nil, result.Error
 	}
 
-	var er EventRequest
+	return result, nil
+}
+
+// TransactionRequest is a list of events produced atomically to a single cluster.
+type TransactionRequest struct {
+	Cluster string             `json:"cluster"`
+	Events  []TransactionEvent `json:"events"`
+}
+
+type transactionResponse struct {
+	Message  string   `json:"message,omitempty"`
+	Messages []string `json:"messages,omitempty"`
+}
+
+// PublishTransaction produces all events of the request in a single Kafka transaction.
+func (rh router) PublishTransaction(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
 
-	body, _ := ioutil.ReadAll(r.Body)
+	if !authorized(w, r) {
+		return
+	}
+
+	var tr TransactionRequest
+
+	if re := decodeBody(w, r, &tr); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
 
-	if err := json.Unmarshal(body, &er); err != nil {
-		writeErrorResponse(w, log, "error deserializing request body", err)
+	if re := validateTransactionRequest(tr); re != nil {
+		writeErrorResponse(w, log, "", re)
 		return
 	}
 
-	log.Debug().Msg(fmt.Sprintf("%s: %s", r.Method, er))
+	// every event of the transaction counts against the rate limits
+	caller := principal(r)
+	if err := allowTransaction(caller, tr.Cluster, tr.Events); err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
 
-	result := rh.kp.Produce(ProduceOptions{
-		Context: r.Context(),
-		Log:     log,
-		Cluster: er.Cluster,
-		Topic:   er.Topic,
-		Key:     er.Key,
-		Data:    er.Data,
+	result := rh.kp.ProduceTransaction(TransactionOptions{
+		Context:   r.Context(),
+		Log:       log,
+		Cluster:   tr.Cluster,
+		Events:    tr.Events,
+		Principal: caller,
 	})
 
 	if result.Error != nil {
@@ -146,31 +341,202 @@ func (rh router) PublishEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b, _ := json.Marshal(eventResponse{result.Message})
+	b, _ := json.Marshal(transactionResponse{"committed", result.Messages})
 
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(b))
+	w.Write(b)
+}
+
+// spoolEvent writes the message to the spool to be produced once the cluster recovers.
+// Messages the cluster would reject are refused instead, they could never be replayed.
+func spoolEvent(options ProduceOptions) (*Result, error) {
+	// the transforms are applied again when replaying
+	prepared, err := prepareProduceOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, result := newMessage(prepared.Topic, prepared.Key, prepared.Data)
+	if result != nil {
+		return nil, newAPIError(http.StatusBadRequest, codeValidationFailed, result.Message, false, result.Error)
+	}
+
+	if re := checkMessageBytes(prepared.Cluster, msg); re != nil {
+		return nil, re
+	}
+
+	if known, checked := clusterHealthChecker.TopicKnown(options.Cluster, options.Topic); checked && !known {
+		return nil, newTopicNotFoundError(options.Topic, nil)
+	}
+
+	if err := messageSpool.Spool(options); err != nil {
+		if errors.Is(err, errSpoolFull) {
+			return nil, err
+		}
+
+		return nil, newAPIError(http.StatusInternalServerError, codeInternal, "error spooling message", false, err)
+	}
+
+	return &Result{Message: "cluster unavailable, message spooled", KeySize: len(msg.Key), ValueSize: len(msg.Value), Spooled: true}, nil
+}
+
+// GetSpoolStats returns the depth of the spool for each cluster.
+func (rh router) GetSpoolStats(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+
+	if !authorized(w, r) {
+		return
+	}
+
+	stats := map[string]spoolStats{}
+	if messageSpool != nil {
+		stats = messageSpool.Stats()
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"enabled":  messageSpool != nil,
+		"clusters": stats,
+	})
+
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// DebugVars serves the expvar metrics, which include cluster and principal names.
+func (rh router) DebugVars(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r) {
+		return
+	}
+
+	expvar.Handler().ServeHTTP(w, r)
+}
+
+// authorized validates the optional API token, writing the error response if it fails.
+func authorized(w http.ResponseWriter, r *http.Request) bool {
+	if err := checkAPIToken(r.Header.Get("X-API-TOKEN"), r.RemoteAddr); err != nil {
+		writeErrorResponse(w, hlog.FromRequest(r), "", err)
+		return false
+	}
+
+	return true
+}
+
+// checkAPIToken returns an error if API auth is enabled and the token doesn't match.
+func checkAPIToken(apiToken, remoteAddr string) *apiError {
+	if !Config.EnableAPIAuth || validAPIToken(apiToken) {
+		return nil
+	}
+
+	return &apiError{
+		Status:  http.StatusUnauthorized,
+		Code:    codeUnauthorized,
+		Message: "API Token Request Failed",
+		Err:     fmt.Errorf("RemoteAddress %s", remoteAddr),
+	}
+}
+
+// requireAPIToken returns an error unless the request has the API token, even when enableApiAuth
+// is false. Used by the routes that read from or change the clusters.
+func requireAPIToken(r *http.Request) *apiError {
+	if !validAPIToken(r.Header.Get("X-API-TOKEN")) {
+		return newAPIError(http.StatusUnauthorized, codeUnauthorized, "API Token Request Failed", false, fmt.Errorf("RemoteAddress %s", r.RemoteAddr))
+	}
+
+	return nil
+}
+
+// validAPIToken compares the token in constant time, so the API token can't be guessed from the
+// response times.
+func validAPIToken(apiToken string) bool {
+	return len(Secrets.APIToken) > 0 && subtle.ConstantTimeCompare([]byte(apiToken), []byte(Secrets.APIToken)) == 1
+}
+
+// principal identifies the caller, a hash of the API token if accepted, otherwise the remote address.
+func principal(r *http.Request) string {
+	return principalOf(r.Header.Get("X-API-TOKEN"), r.RemoteAddr)
+}
+
+// principalOf identifies the caller by the API token once it was checked, an unchecked token
+// could be anything so the remote IP is used instead.
+func principalOf(apiToken, remoteAddr string) string {
+	if Config.EnableAPIAuth && len(apiToken) > 0 && checkAPIToken(apiToken, remoteAddr) == nil {
+		sum := sha256.Sum256([]byte(apiToken))
+		return fmt.Sprintf("token:%x", sum[:8])
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+
+	return host
 }
 
 type errorResponse struct {
-	Error   string `json:"error,omitempty"`
-	Message string `json:"message,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Message   string       `json:"message,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Retriable bool         `json:"retriable"`
+	Fields    []fieldError `json:"fields,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
 }
 
+// writeErrorResponse writes the error with the status and code it maps to, the message
+// replaces the default message of the error if provided. The details of the error are only
+// logged, along with the request ID returned to the caller, unless exposeErrorDetails is set.
 func writeErrorResponse(w http.ResponseWriter, log *zerolog.Logger, message string, err error) {
-	er := errorResponse{}
+	ae := classifyError(err)
+
+	er := errorResponse{
+		Message:   ae.Message,
+		Code:      ae.Code,
+		Retriable: ae.Retriable,
+		Fields:    ae.Fields,
+		RequestID: w.Header().Get(requestIDHeader),
+	}
 
 	if len(message) > 0 {
 		er.Message = message
 	}
 
-	if err != nil {
-		er.Error = err.Error()
+	if ae.Err != nil {
+		er.Error = ae.Err.Error()
+	}
+
+	if ae.Status >= http.StatusInternalServerError {
+		log.Error().Msgf("%+v", er)
+	} else {
+		log.Warn().Msgf("%+v", er)
 	}
 
-	log.Error().Msgf("%+v", er)
+	if !Config.ExposeErrorDetails {
+		er.Error = ""
+	}
+
+	if ae.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(ae.RetryAfter))
+	} else if ae.Retriable && ae.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds()))
+	}
 
 	b, _ := json.Marshal(er)
-	w.WriteHeader(http.StatusInternalServerError)
+	w.WriteHeader(ae.Status)
+	w.Write(b)
+}
+
+// writeJSON writes the value as the response body with the status.
+func writeJSON(w http.ResponseWriter, log *zerolog.Logger, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	w.WriteHeader(status)
 	w.Write(b)
 }
@@ -40,7 +40,7 @@ func TestConfigure(t *testing.T) {
 		return nil
 	})
 
-	assert.Equal(t, 4, count)
+	assert.Equal(t, 19, count)
 }
 
 func TestHealthSuccess(t *testing.T) {
@@ -66,3 +66,49 @@ func TestCheckStatusPingSuccess(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
+
+func TestDebugVarsUnauthorized(t *testing.T) {
+	r := setup()
+	Config.EnableAPIAuth = true
+	defer func() {
+		Config.EnableAPIAuth = false
+	}()
+
+	req := httptest.NewRequest("GET", "/debug/vars", nil)
+	w := httptest.NewRecorder()
+	r.DebugVars(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+
+	req.Header.Set("X-API-TOKEN", "TestApiToken")
+	w = httptest.NewRecorder()
+	r.DebugVars(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Contains(t, w.Body.String(), "spoolDepth")
+}
+
+func TestRequireAPIToken(t *testing.T) {
+	setup()
+
+	req := httptest.NewRequest("GET", "/clusters/kafka-cl01/topics/orders/tail", nil)
+	assert.NotNil(t, requireAPIToken(req))
+
+	req.Header.Set("X-API-TOKEN", "testapitoken")
+	assert.NotNil(t, requireAPIToken(req))
+
+	req.Header.Set("X-API-TOKEN", "TestApiToken")
+	assert.Nil(t, requireAPIToken(req))
+}
+
+func TestCheckAPIToken(t *testing.T) {
+	setup()
+	Config.EnableAPIAuth = true
+	defer func() {
+		Config.EnableAPIAuth = false
+	}()
+
+	assert.NotNil(t, checkAPIToken("", "192.0.2.1:1234"))
+	assert.NotNil(t, checkAPIToken("testapitoken", "192.0.2.1:1234"))
+	assert.Nil(t, checkAPIToken("TestApiToken", "192.0.2.1:1234"))
+}
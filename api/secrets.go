@@ -83,6 +83,14 @@ func SetCertData(data []byte) {
 }
 
 func kafkaClusterLookup(cluster string) (kc KafkaConfig, err error) {
+	// the secrets.json failed to load
+	if Secrets == nil {
+		e := newClusterNotFoundError(cluster)
+		e.Err = fmt.Errorf("%s not found, no secrets are loaded", cluster)
+
+		return kc, e
+	}
+
 	if value, ok := Secrets.KafkaSecrets[cluster]; ok {
 		return value, err
 	}
@@ -92,5 +100,9 @@ func kafkaClusterLookup(cluster string) (kc KafkaConfig, err error) {
 		keys = append(keys, key)
 	}
 
-	return kc, fmt.Errorf("%s not found. Available clusters:\n%v", cluster, strings.Join(keys, ","))
+	// the available clusters are only logged, never returned to the caller
+	e := newClusterNotFoundError(cluster)
+	e.Err = fmt.Errorf("%s not found. Available clusters:\n%v", cluster, strings.Join(keys, ","))
+
+	return kc, e
 }
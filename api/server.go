@@ -11,6 +11,11 @@ import (
 	"github.com/rs/zerolog/hlog"
 )
 
+const (
+	requestIDHeader      = "Request-Id"
+	defaultServerTimeout = 15 * time.Second
+)
+
 func Serve() {
 	hostname, _ := os.Hostname()
 	producer := newProducer()
@@ -38,23 +43,78 @@ func Serve() {
 	router.Use(hlog.RemoteAddrHandler("ip"))
 	router.Use(hlog.UserAgentHandler("user_agent"))
 	router.Use(hlog.RefererHandler("referer"))
-	router.Use(hlog.RequestIDHandler("req_id", "Request-Id"))
+	router.Use(hlog.RequestIDHandler("req_id", requestIDHeader))
 
 	km, err := newKafkaMiddleware()
 	if err != nil {
 		log.Fatal().Err(err).Msg("Kafka Init Error")
 	}
 	router.Use(km.Handler)
+	router.Use(openAPIValidator)
+
+	done := make(chan bool)
+	defer close(done)
+
+	go clusterHealthChecker.Run(done)
+
+	if Config.Spool.Enabled {
+		messageSpool, err = newSpooler(producer)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Spool Init Error")
+		}
+
+		go messageSpool.Run(done)
+	}
+
+	rateLimiter.SetRules(Config.RateLimits)
+
+	logRedactor, err = newRedactor(Config.LogRedaction)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Log Redaction Init Error")
+	}
+
+	payloadTransforms, err = newTransformPipelines(Config.Transforms)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Transforms Init Error")
+	}
+
+	messageKeyRules, err = newKeyRules(Config.KeyRules)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Key Rules Init Error")
+	}
+
+	topicAdminPolicy, err = newTopicPolicy(Config.TopicAdmin)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Topic Admin Init Error")
+	}
+
+	if Config.Transactions.Enabled {
+		transactionalPools = newTransactionalPools()
+	}
+
+	if Config.Idempotency.Enabled {
+		idempotencyKeys = newIdempotencyCache(Config.Idempotency)
+	}
 
 	configureRouter(router, producer)
 
+	if Config.GRPCPort > 0 {
+		gs, err := newGRPCServer(producer, &log)
+		if err != nil {
+			log.Fatal().Err(err).Msg("gRPC Init Error")
+		}
+		defer gs.GracefulStop()
+
+		go serveGRPC(gs, &log)
+	}
+
 	address := fmt.Sprintf(":%v", Config.ServerPort)
 
 	hs := &http.Server{
 		Addr: address,
 		// set timeouts to avoid Slowloris attacks.
-		WriteTimeout: time.Second * 15,
-		ReadTimeout:  time.Second * 15,
+		WriteTimeout: serverTimeout(Config.WriteTimeoutSeconds),
+		ReadTimeout:  serverTimeout(Config.ReadTimeoutSeconds),
 		IdleTimeout:  time.Second * 60,
 		Handler:      router,
 	}
@@ -69,3 +129,12 @@ func Serve() {
 		panic(hs.ListenAndServe())
 	}
 }
+
+// serverTimeout returns the configured timeout, defaulting to 15 seconds.
+func serverTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultServerTimeout
+	}
+
+	return time.Duration(seconds) * time.Second
+}
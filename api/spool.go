@@ -0,0 +1,637 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultSpoolMaxBytes              = 1 << 30
+	defaultSpoolMaxAgeSeconds         = 86400
+	defaultSpoolSegmentBytes          = 16 << 20
+	defaultSpoolReplayIntervalSeconds = 5
+
+	spoolSegmentExt      = ".seg"
+	spoolPositionFile    = "position"
+	spoolSegmentIDFormat = "%020d"
+)
+
+var (
+	errSpoolFull = errors.New("spool is full")
+
+	// spoolDepth exposes the number of spooled messages per cluster.
+	spoolDepth = expvar.NewMap("spoolDepth")
+	// spoolDropped exposes the number of spooled messages dropped per cluster, either expired or
+	// rejected by the cluster.
+	spoolDropped = expvar.NewMap("spoolDropped")
+
+	// messageSpool is nil unless the spool is enabled.
+	messageSpool *spooler
+)
+
+type spoolConfig struct {
+	Enabled               bool  `json:"enabled"`
+	MaxBytes              int64 `json:"maxBytes,omitempty"`
+	MaxAgeSeconds         int   `json:"maxAgeSeconds,omitempty"`
+	SegmentBytes          int64 `json:"segmentBytes,omitempty"`
+	ReplayIntervalSeconds int   `json:"replayIntervalSeconds,omitempty"`
+}
+
+// spoolRecord is a message written to a spool segment, one JSON document per line.
+type spoolRecord struct {
+	Cluster    string                 `json:"cluster"`
+	Topic      string                 `json:"topic"`
+	Key        interface{}            `json:"key"`
+	Data       map[string]interface{} `json:"data"`
+	Durability string                 `json:"durability,omitempty"`
+	Principal  string                 `json:"principal,omitempty"`
+	Headers    map[string]string      `json:"headers,omitempty"`
+	SpooledAt  time.Time              `json:"spooledAt"`
+}
+
+type spoolStats struct {
+	Depth    int        `json:"depth"`
+	Bytes    int64      `json:"bytes"`
+	Segments int        `json:"segments"`
+	Oldest   *time.Time `json:"oldest,omitempty"`
+}
+
+// spooler holds a disk-backed write-ahead spool per cluster.
+type spooler struct {
+	cfg    spoolConfig
+	kp     kafkaProducer
+	spools map[string]*clusterSpool
+}
+
+// newSpooler opens the spool of every configured cluster within the temp directory.
+func newSpooler(kp kafkaProducer) (*spooler, error) {
+	cfg := Config.Spool
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultSpoolMaxBytes
+	}
+	if cfg.MaxAgeSeconds <= 0 {
+		cfg.MaxAgeSeconds = defaultSpoolMaxAgeSeconds
+	}
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = defaultSpoolSegmentBytes
+	}
+	if cfg.ReplayIntervalSeconds <= 0 {
+		cfg.ReplayIntervalSeconds = defaultSpoolReplayIntervalSeconds
+	}
+
+	s := &spooler{
+		cfg:    cfg,
+		kp:     kp,
+		spools: map[string]*clusterSpool{},
+	}
+
+	for _, cluster := range Config.KafkaBrokerGroups {
+		dir := filepath.Join(os.Getenv("KAFKA_PRODUCER_PROXY_TEMP_DIR"), "spool", cluster)
+
+		cs, err := openClusterSpool(dir, cluster, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		s.spools[cluster] = cs
+	}
+
+	return s, nil
+}
+
+func (s *spooler) lookup(cluster string) (*clusterSpool, error) {
+	for name, cs := range s.spools {
+		if strings.EqualFold(name, cluster) {
+			return cs, nil
+		}
+	}
+
+	return nil, newClusterNotFoundError(cluster)
+}
+
+// Pending returns true if the cluster has spooled messages waiting to be replayed.
+func (s *spooler) Pending(cluster string) bool {
+	cs, err := s.lookup(cluster)
+	if err != nil {
+		return false
+	}
+
+	return cs.Stats().Depth > 0
+}
+
+// Spool writes the message to the cluster spool.
+func (s *spooler) Spool(options ProduceOptions) error {
+	cs, err := s.lookup(options.Cluster)
+	if err != nil {
+		return err
+	}
+
+	return cs.Append(spoolRecord{
+		Cluster:    options.Cluster,
+		Topic:      options.Topic,
+		Key:        options.Key,
+		Data:       options.Data,
+		SpooledAt:  time.Now(),
+		Durability: options.Durability,
+		Principal:  options.Principal,
+		Headers:    options.Headers,
+	})
+}
+
+// Stats returns the spool statistics per cluster.
+func (s *spooler) Stats() map[string]spoolStats {
+	stats := map[string]spoolStats{}
+
+	for cluster, cs := range s.spools {
+		stats[cluster] = cs.Stats()
+	}
+
+	return stats
+}
+
+// Run replays the spooled messages on an interval.
+// This function is blocking so it is meant to be used by running `go Run(done)`
+func (s *spooler) Run(done chan bool) {
+	ticker := time.NewTicker(time.Second * time.Duration(s.cfg.ReplayIntervalSeconds))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for cluster, cs := range s.spools {
+				if !clusterHealthChecker.IsHealthy(cluster) {
+					continue
+				}
+
+				if err := s.replay(cs); err != nil {
+					log.Warn().Err(err).Msgf("replaying spool for cluster %s stopped", cluster)
+				}
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// replay produces the spooled messages in order, stopping at the first retriable failure.
+// Messages the cluster rejects are dropped, otherwise they would block the spool forever.
+func (s *spooler) replay(cs *clusterSpool) error {
+	cs.replayMu.Lock()
+	defer cs.replayMu.Unlock()
+
+	ctx := context.WithValue(context.Background(), producerctxkey, producerCTXs)
+	maxAge := time.Second * time.Duration(s.cfg.MaxAgeSeconds)
+
+	for {
+		rec, n, err := cs.Peek()
+		if err != nil {
+			return err
+		}
+
+		if rec == nil {
+			return nil
+		}
+
+		if time.Since(rec.SpooledAt) > maxAge {
+			log.Warn().Msgf("dropping spooled message for %s/%s, older than %v", rec.Cluster, rec.Topic, maxAge)
+			spoolDropped.Add(cs.cluster, 1)
+		} else {
+			result := s.kp.Produce(ProduceOptions{
+				Context:    ctx,
+				Log:        &log.Logger,
+				Cluster:    rec.Cluster,
+				Topic:      rec.Topic,
+				Key:        rec.Key,
+				Data:       rec.Data,
+				Durability: rec.Durability,
+				Principal:  rec.Principal,
+				Headers:    rec.Headers,
+			})
+
+			if result.Error != nil {
+				if classifyError(result.Error).Retriable {
+					return result.Error
+				}
+
+				log.Error().Err(result.Error).Msgf("dropping spooled message for %s/%s, rejected by the cluster", rec.Cluster, rec.Topic)
+				spoolDropped.Add(cs.cluster, 1)
+			}
+		}
+
+		if err := cs.Commit(n); err != nil {
+			return err
+		}
+	}
+}
+
+// clusterSpool is an append only log split into segment files. The read position is
+// persisted so messages are replayed once and in order, even after a restart.
+type clusterSpool struct {
+	mu sync.Mutex
+	// replayMu is held while replaying, the read position only moves for the replay meanwhile.
+	replayMu sync.Mutex
+	cfg      spoolConfig
+	dir      string
+	cluster  string
+
+	segments   []int64
+	nextID     int64
+	writer     *os.File
+	writerSize int64
+
+	readSegment int64
+	readOffset  int64
+
+	depth  int
+	bytes  int64
+	oldest *time.Time
+}
+
+func openClusterSpool(dir, cluster string, cfg spoolConfig) (*clusterSpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	cs := &clusterSpool{
+		cfg:     cfg,
+		dir:     dir,
+		cluster: cluster,
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), spoolSegmentExt) {
+			continue
+		}
+
+		id, err := strconv.ParseInt(strings.TrimSuffix(f.Name(), spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		cs.segments = append(cs.segments, id)
+		cs.bytes += f.Size()
+	}
+
+	sort.Slice(cs.segments, func(i, j int) bool { return cs.segments[i] < cs.segments[j] })
+
+	if err := cs.readPosition(); err != nil {
+		return nil, err
+	}
+
+	if err := cs.removeReplayedSegments(); err != nil {
+		return nil, err
+	}
+
+	// segment ids are never reused, the read position may still point at a removed segment
+	cs.nextID = cs.readSegment + 1
+	if len(cs.segments) > 0 && cs.segments[len(cs.segments)-1] >= cs.nextID {
+		cs.nextID = cs.segments[len(cs.segments)-1] + 1
+	}
+
+	if err := cs.count(); err != nil {
+		return nil, err
+	}
+
+	spoolDepth.Add(cluster, int64(cs.depth))
+
+	return cs, nil
+}
+
+func (cs *clusterSpool) segmentPath(id int64) string {
+	return filepath.Join(cs.dir, fmt.Sprintf(spoolSegmentIDFormat, id)+spoolSegmentExt)
+}
+
+func (cs *clusterSpool) readPosition() error {
+	b, err := ioutil.ReadFile(filepath.Join(cs.dir, spoolPositionFile))
+	if os.IsNotExist(err) {
+		if len(cs.segments) > 0 {
+			cs.readSegment = cs.segments[0]
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	_, err = fmt.Sscanf(string(b), "%d %d", &cs.readSegment, &cs.readOffset)
+
+	return err
+}
+
+func (cs *clusterSpool) writePosition() error {
+	return ioutil.WriteFile(
+		filepath.Join(cs.dir, spoolPositionFile),
+		[]byte(fmt.Sprintf("%d %d", cs.readSegment, cs.readOffset)),
+		0644,
+	)
+}
+
+// removeReplayedSegments removes the segments before the read position, left behind when the proxy
+// stopped between persisting the position and removing the segment.
+func (cs *clusterSpool) removeReplayedSegments() error {
+	for len(cs.segments) > 0 && cs.segments[0] < cs.readSegment {
+		path := cs.segmentPath(cs.segments[0])
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+
+		cs.bytes -= fi.Size()
+		cs.segments = cs.segments[1:]
+	}
+
+	return nil
+}
+
+// count scans the unreplayed records to restore the depth after a restart.
+func (cs *clusterSpool) count() error {
+	for _, id := range cs.segments {
+		if id < cs.readSegment {
+			continue
+		}
+
+		f, err := os.Open(cs.segmentPath(id))
+		if err != nil {
+			return err
+		}
+
+		if id == cs.readSegment {
+			if _, err := f.Seek(cs.readOffset, io.SeekStart); err != nil {
+				f.Close()
+				return err
+			}
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), int(cs.cfg.MaxBytes))
+		for scanner.Scan() {
+			if cs.oldest == nil {
+				var rec spoolRecord
+				if err := json.Unmarshal(scanner.Bytes(), &rec); err == nil {
+					cs.oldest = &rec.SpooledAt
+				}
+			}
+			cs.depth++
+		}
+
+		f.Close()
+
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Append writes the record to the current segment, rotating it when it is full.
+func (cs *clusterSpool) Append(rec spoolRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// the replay drops expired messages only while the cluster is healthy
+	if cs.replayMu.TryLock() {
+		err := cs.expire(time.Now())
+		cs.replayMu.Unlock()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if cs.bytes+int64(len(b)) > cs.cfg.MaxBytes {
+		return errSpoolFull
+	}
+
+	if cs.writer == nil || cs.writerSize >= cs.cfg.SegmentBytes {
+		if err := cs.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := cs.writer.Write(b); err != nil {
+		return err
+	}
+
+	// the spool is only useful if the message survives a crash
+	if err := cs.writer.Sync(); err != nil {
+		return err
+	}
+
+	cs.writerSize += int64(len(b))
+	cs.bytes += int64(len(b))
+	cs.depth++
+	if cs.oldest == nil {
+		cs.oldest = &rec.SpooledAt
+	}
+	spoolDepth.Add(cs.cluster, 1)
+
+	return nil
+}
+
+// rotate closes the current segment and starts a new one.
+func (cs *clusterSpool) rotate() error {
+	if cs.writer != nil {
+		if err := cs.writer.Close(); err != nil {
+			return err
+		}
+	}
+
+	id := cs.nextID
+
+	f, err := os.OpenFile(cs.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if len(cs.segments) == 0 {
+		cs.readSegment = id
+		cs.readOffset = 0
+
+		if err := cs.writePosition(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	cs.segments = append(cs.segments, id)
+	cs.nextID = id + 1
+	cs.writer = f
+	cs.writerSize = 0
+
+	return nil
+}
+
+// expire drops the records older than maxAgeSeconds from the head of the spool. cs.mu and
+// cs.replayMu must be held.
+func (cs *clusterSpool) expire(now time.Time) error {
+	if cs.cfg.MaxAgeSeconds <= 0 {
+		return nil
+	}
+
+	maxAge := time.Second * time.Duration(cs.cfg.MaxAgeSeconds)
+
+	for cs.oldest != nil && now.Sub(*cs.oldest) > maxAge {
+		rec, n, err := cs.peek()
+		if err != nil || rec == nil {
+			return err
+		}
+
+		if now.Sub(rec.SpooledAt) <= maxAge {
+			return nil
+		}
+
+		log.Warn().Msgf("dropping spooled message for %s/%s, older than %v", rec.Cluster, rec.Topic, maxAge)
+		spoolDropped.Add(cs.cluster, 1)
+
+		if err := cs.commit(n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Peek returns the next record to replay along with its size in bytes, or nil if the spool is empty.
+// Fully replayed segments are removed along the way.
+func (cs *clusterSpool) Peek() (*spoolRecord, int64, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.peek()
+}
+
+func (cs *clusterSpool) peek() (*spoolRecord, int64, error) {
+	for len(cs.segments) > 0 {
+		f, err := os.Open(cs.segmentPath(cs.readSegment))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if _, err := f.Seek(cs.readOffset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+
+		line, err := bufio.NewReader(f).ReadBytes('\n')
+		f.Close()
+
+		if err == nil {
+			var rec spoolRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return nil, 0, err
+			}
+
+			cs.oldest = &rec.SpooledAt
+
+			return &rec, int64(len(line)), nil
+		} else if err != io.EOF {
+			return nil, 0, err
+		}
+
+		// nothing left in the segment being written to
+		if cs.writer != nil && cs.readSegment == cs.segments[len(cs.segments)-1] {
+			return nil, 0, nil
+		}
+
+		if err := cs.removeReadSegment(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return nil, 0, nil
+}
+
+// removeReadSegment moves to the next segment and deletes the fully replayed one. The position is
+// persisted first, a segment left behind by a crash is removed on the next start instead of
+// being replayed again.
+func (cs *clusterSpool) removeReadSegment() error {
+	id := cs.readSegment
+	path := cs.segmentPath(id)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	offset := cs.readOffset
+
+	// ids are never reused, so the next id is safe even before its segment is created
+	cs.readSegment, cs.readOffset = id+1, 0
+	if len(cs.segments) > 1 {
+		cs.readSegment = cs.segments[1]
+	}
+
+	if err := cs.writePosition(); err != nil {
+		cs.readSegment, cs.readOffset = id, offset
+		return err
+	}
+
+	cs.bytes -= fi.Size()
+	cs.segments = cs.segments[1:]
+
+	return os.Remove(path)
+}
+
+// Commit advances the read position past a replayed record.
+func (cs *clusterSpool) Commit(n int64) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.commit(n)
+}
+
+func (cs *clusterSpool) commit(n int64) error {
+	cs.readOffset += n
+	cs.depth--
+	spoolDepth.Add(cs.cluster, -1)
+
+	if cs.depth == 0 {
+		cs.oldest = nil
+	}
+
+	return cs.writePosition()
+}
+
+// Stats returns the current spool statistics.
+func (cs *clusterSpool) Stats() spoolStats {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return spoolStats{
+		Depth:    cs.depth,
+		Bytes:    cs.bytes,
+		Segments: len(cs.segments),
+		Oldest:   cs.oldest,
+	}
+}
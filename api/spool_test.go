@@ -0,0 +1,182 @@
+package api
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+// topicErrorProducer fails the messages of the topics with their error.
+type topicErrorProducer struct {
+	countingProducer
+	errs     map[string]error
+	produced []string
+}
+
+func (p *topicErrorProducer) Produce(options ProduceOptions) *Result {
+	if err, ok := p.errs[options.Topic]; ok {
+		return &Result{Error: err}
+	}
+
+	p.produced = append(p.produced, options.Topic)
+
+	return &Result{Message: options.Topic}
+}
+
+func TestClusterSpoolReplayOrder(t *testing.T) {
+	dir := t.TempDir()
+	cfg := spoolConfig{MaxBytes: 1 << 20, SegmentBytes: 128}
+
+	cs, err := openClusterSpool(dir, "kafka-cl01", cfg)
+	assert.Nil(t, err)
+
+	for _, topic := range []string{"topic-1", "topic-2", "topic-3"} {
+		err := cs.Append(spoolRecord{Cluster: "kafka-cl01", Topic: topic, SpooledAt: time.Now()})
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, 3, cs.Stats().Depth)
+
+	rec, n, err := cs.Peek()
+	assert.Nil(t, err)
+	assert.Equal(t, "topic-1", rec.Topic)
+	assert.Nil(t, cs.Commit(n))
+
+	// reopening restores the read position
+	cs, err = openClusterSpool(dir, "kafka-cl01", cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, cs.Stats().Depth)
+
+	for _, topic := range []string{"topic-2", "topic-3"} {
+		rec, n, err := cs.Peek()
+		assert.Nil(t, err)
+		assert.Equal(t, topic, rec.Topic)
+		assert.Nil(t, cs.Commit(n))
+	}
+
+	rec, _, err = cs.Peek()
+	assert.Nil(t, err)
+	assert.Nil(t, rec)
+	assert.Equal(t, 0, cs.Stats().Depth)
+}
+
+func TestClusterSpoolFull(t *testing.T) {
+	cs, err := openClusterSpool(t.TempDir(), "kafka-cl01", spoolConfig{MaxBytes: 64, SegmentBytes: 64})
+	assert.Nil(t, err)
+
+	err = cs.Append(spoolRecord{Cluster: "kafka-cl01", Topic: "topic", Data: map[string]interface{}{"field": "a value that is too long to fit"}})
+
+	assert.Equal(t, errSpoolFull, err)
+}
+
+func TestClusterSpoolSegmentIDs(t *testing.T) {
+	dir := t.TempDir()
+	cfg := spoolConfig{MaxBytes: 1 << 20, SegmentBytes: 1}
+
+	cs, err := openClusterSpool(dir, "kafka-cl01", cfg)
+	assert.Nil(t, err)
+
+	for _, topic := range []string{"topic-1", "topic-2"} {
+		assert.Nil(t, cs.Append(spoolRecord{Cluster: "kafka-cl01", Topic: topic, SpooledAt: time.Now()}))
+	}
+
+	// drain the spool after a restart, removing every segment
+	cs, err = openClusterSpool(dir, "kafka-cl01", cfg)
+	assert.Nil(t, err)
+
+	for {
+		rec, n, err := cs.Peek()
+		assert.Nil(t, err)
+		if rec == nil {
+			break
+		}
+		assert.Nil(t, cs.Commit(n))
+	}
+
+	assert.Equal(t, 0, cs.Stats().Segments)
+
+	assert.Nil(t, cs.Append(spoolRecord{Cluster: "kafka-cl01", Topic: "topic-3", SpooledAt: time.Now()}))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, spoolPositionFile))
+	assert.Nil(t, err)
+	assert.Equal(t, "3 0", string(b))
+
+	// the message isn't stranded after another restart
+	cs, err = openClusterSpool(dir, "kafka-cl01", cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, cs.Stats().Depth)
+
+	rec, _, err := cs.Peek()
+	assert.Nil(t, err)
+	assert.Equal(t, "topic-3", rec.Topic)
+}
+
+func TestClusterSpoolReplayedSegmentLeftBehind(t *testing.T) {
+	dir := t.TempDir()
+	cfg := spoolConfig{MaxBytes: 1 << 20, SegmentBytes: 1}
+
+	cs, err := openClusterSpool(dir, "kafka-cl01", cfg)
+	assert.Nil(t, err)
+
+	for _, topic := range []string{"topic-1", "topic-2"} {
+		assert.Nil(t, cs.Append(spoolRecord{Cluster: "kafka-cl01", Topic: topic, SpooledAt: time.Now()}))
+	}
+
+	// stopped after persisting the position past the first segment, before removing it
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, spoolPositionFile), []byte("2 0"), 0644))
+
+	cs, err = openClusterSpool(dir, "kafka-cl01", cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, cs.Stats().Depth)
+	assert.Equal(t, 1, cs.Stats().Segments)
+	assert.NoFileExists(t, cs.segmentPath(1))
+
+	rec, _, err := cs.Peek()
+	assert.Nil(t, err)
+	assert.Equal(t, "topic-2", rec.Topic)
+}
+
+func TestClusterSpoolExpiresOnAppend(t *testing.T) {
+	cs, err := openClusterSpool(t.TempDir(), "kafka-cl01", spoolConfig{MaxBytes: 1 << 20, SegmentBytes: 1 << 20, MaxAgeSeconds: 60})
+	assert.Nil(t, err)
+
+	assert.Nil(t, cs.Append(spoolRecord{Cluster: "kafka-cl01", Topic: "expired", SpooledAt: time.Now().Add(-time.Hour)}))
+	assert.Nil(t, cs.Append(spoolRecord{Cluster: "kafka-cl01", Topic: "recent", SpooledAt: time.Now()}))
+
+	assert.Equal(t, 1, cs.Stats().Depth)
+
+	rec, _, err := cs.Peek()
+	assert.Nil(t, err)
+	assert.Equal(t, "recent", rec.Topic)
+}
+
+func TestSpoolerReplay(t *testing.T) {
+	cs, err := openClusterSpool(t.TempDir(), "kafka-cl01", spoolConfig{MaxBytes: 1 << 20, SegmentBytes: 1 << 20})
+	assert.Nil(t, err)
+
+	for _, topic := range []string{"topic-1", "invalid", "topic-2", "unavailable", "topic-3"} {
+		assert.Nil(t, cs.Append(spoolRecord{Cluster: "kafka-cl01", Topic: topic, SpooledAt: time.Now()}))
+	}
+
+	kp := &topicErrorProducer{errs: map[string]error{
+		"invalid":     validationError([]fieldError{{"key", "missing_value", "key is required"}}),
+		"unavailable": kafka.NewError(kafka.ErrAllBrokersDown, "all brokers down", false),
+	}}
+
+	s := &spooler{cfg: spoolConfig{MaxAgeSeconds: 60}, kp: kp, spools: map[string]*clusterSpool{"kafka-cl01": cs}}
+
+	// the rejected message is dropped, the unavailable cluster stops the replay
+	assert.NotNil(t, s.replay(cs))
+	assert.Equal(t, []string{"topic-1", "topic-2"}, kp.produced)
+	assert.Equal(t, 2, cs.Stats().Depth)
+
+	delete(kp.errs, "unavailable")
+
+	assert.Nil(t, s.replay(cs))
+	assert.Equal(t, []string{"topic-1", "topic-2", "unavailable", "topic-3"}, kp.produced)
+	assert.Equal(t, 0, cs.Stats().Depth)
+}
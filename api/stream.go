@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+const (
+	ndjsonContentType        = "application/x-ndjson"
+	defaultStreamMaxInFlight = 16
+)
+
+type streamingConfig struct {
+	// MaxInFlight is the number of events of a stream produced concurrently. Defaults to 16.
+	MaxInFlight int `json:"maxInFlight,omitempty"`
+}
+
+func streamMaxInFlight() int {
+	if Config.Streaming.MaxInFlight <= 0 {
+		return defaultStreamMaxInFlight
+	}
+
+	return Config.Streaming.MaxInFlight
+}
+
+// streamResult is written for every line of the stream, in the order of the lines.
+type streamResult struct {
+	Line      int          `json:"line"`
+	Status    int          `json:"status"`
+	Message   string       `json:"message,omitempty"`
+	Cluster   string       `json:"cluster,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Retriable bool         `json:"retriable,omitempty"`
+	Fields    []fieldError `json:"fields,omitempty"`
+}
+
+type streamJob struct {
+	line   int
+	er     EventRequest
+	result chan streamResult
+}
+
+func newStreamError(line int, err error) streamResult {
+	ae := classifyError(err)
+
+	return streamResult{
+		Line:      line,
+		Status:    ae.Status,
+		Message:   ae.Message,
+		Code:      ae.Code,
+		Retriable: ae.Retriable,
+		Fields:    ae.Fields,
+	}
+}
+
+// PublishStream produces every line of an NDJSON body as an event, writing an NDJSON result per
+// line as soon as it and the lines before it completed. Events with the same key are produced in
+// order, others concurrently up to streaming.maxInFlight.
+func (rh router) PublishStream(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+
+	if !authorized(w, r) {
+		return
+	}
+
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != ndjsonContentType {
+		writeErrorResponse(w, log, "", &apiError{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    codeUnsupportedMedia,
+			Message: fmt.Sprintf("content type must be %s", ndjsonContentType),
+		})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	// HTTP/1.1 otherwise discards the unread body once the first result is written
+	if err := rc.EnableFullDuplex(); err != nil {
+		log.Debug().Err(err).Msg("full duplex is not supported")
+	}
+
+	// a stream may outlive the server's timeouts, they apply to every line instead
+	readTimeout := serverTimeout(Config.ReadTimeoutSeconds)
+	writeTimeout := serverTimeout(Config.WriteTimeoutSeconds)
+
+	if err := rc.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+		log.Debug().Err(err).Msg("read deadline not supported")
+	}
+	if err := rc.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		log.Debug().Err(err).Msg("write deadline not supported")
+	}
+
+	maxInFlight := streamMaxInFlight()
+	p := principal(r)
+
+	// the results are queued in the order of the lines, which bounds the events in flight
+	pending := make(chan chan streamResult, maxInFlight)
+
+	workers := make([]chan streamJob, maxInFlight)
+	for i := range workers {
+		workers[i] = make(chan streamJob)
+
+		go func(jobs chan streamJob) {
+			for job := range jobs {
+				result, err := rh.produceEvent(r.Context(), log, p, job.er)
+				if err != nil {
+					job.result <- newStreamError(job.line, err)
+					continue
+				}
+
+				sr := streamResult{Line: job.line, Status: http.StatusOK, Message: result.Message, Cluster: result.Cluster}
+				if result.Spooled {
+					sr.Status = http.StatusAccepted
+				}
+
+				job.result <- sr
+			}
+		}(workers[i])
+	}
+
+	go func() {
+		defer close(pending)
+		defer func() {
+			for _, jobs := range workers {
+				close(jobs)
+			}
+		}()
+
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), int(maxBodyBytes()))
+
+		line := 0
+		for scanner.Scan() {
+			line++
+			rc.SetReadDeadline(time.Now().Add(readTimeout))
+
+			b := bytes.TrimSpace(scanner.Bytes())
+			if len(b) == 0 {
+				continue
+			}
+
+			result := make(chan streamResult, 1)
+
+			select {
+			case pending <- result:
+			case <-r.Context().Done():
+				return
+			}
+
+			er, re := decodeStreamLine(b)
+			if re != nil {
+				result <- newStreamError(line, re)
+				continue
+			}
+
+			workers[streamWorker(er.Key, line, len(workers))] <- streamJob{line, er, result}
+		}
+
+		if err := scanner.Err(); err != nil {
+			result := make(chan streamResult, 1)
+			result <- newStreamError(line+1, streamReadError(err))
+			pending <- result
+		}
+	}()
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	for result := range pending {
+		sr := <-result
+
+		rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+		if err := enc.Encode(sr); err != nil {
+			log.Warn().Err(err).Msg("error writing stream result")
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// decodeStreamLine strictly decodes and validates the event of a line.
+func decodeStreamLine(b []byte) (EventRequest, *apiError) {
+	var er EventRequest
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&er); err != nil {
+		return er, decodeError(err)
+	}
+
+	if dec.More() {
+		return er, &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    codeInvalidJSON,
+			Message: "line must contain a single JSON object",
+		}
+	}
+
+	return er, validateEventRequest(er)
+}
+
+// streamWorker picks the worker of the event, events with the same key always use the same
+// worker to keep them in order.
+func streamWorker(key interface{}, line, workers int) int {
+	if key == nil {
+		return line % workers
+	}
+
+	h := fnv.New32a()
+	fmt.Fprint(h, key)
+
+	return int(h.Sum32() % uint32(workers))
+}
+
+func streamReadError(err error) *apiError {
+	if err == bufio.ErrTooLong {
+		return &apiError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    codeBodyTooLarge,
+			Message: fmt.Sprintf("line must not be larger than %d bytes", maxBodyBytes()),
+			Err:     err,
+		}
+	}
+
+	return newAPIError(http.StatusBadRequest, codeInvalidJSON, "error reading the stream", false, err)
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishStream(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	rh := router{kp: cp}
+
+	body := strings.Join([]string{
+		`{"cluster": "kafka-cl01", "topic": "orders", "key": "a", "data": {"id": 1}}`,
+		``,
+		`{"cluster": "kafka-cl01", "topc": "orders"}`,
+		`{"cluster": "kafka-cl01", "topic": "orders", "key": "a", "data": {"id": 2}}`,
+		`{"cluster": "kafka-cl01"`,
+	}, "\n")
+
+	req := httptest.NewRequest("POST", "/events/stream", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+
+	rh.PublishStream(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	results := []streamResult{}
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		var sr streamResult
+		assert.Nil(t, dec.Decode(&sr))
+		results = append(results, sr)
+	}
+
+	assert.Len(t, results, 4)
+	assert.Equal(t, streamResult{Line: 1, Status: http.StatusOK, Message: "orders", Cluster: "kafka-cl01"}, results[0])
+	assert.Equal(t, 3, results[1].Line)
+	assert.Equal(t, codeUnknownField, results[1].Code)
+	assert.Equal(t, 4, results[2].Line)
+	assert.Equal(t, http.StatusOK, results[2].Status)
+	assert.Equal(t, 5, results[3].Line)
+	assert.Equal(t, codeInvalidJSON, results[3].Code)
+	assert.Equal(t, 2, cp.count)
+}
+
+func TestPublishStreamContentType(t *testing.T) {
+	setup()
+
+	req := httptest.NewRequest("POST", "/events/stream", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router{kp: &countingProducer{}}.PublishStream(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+// results are streamed back while the request body is still being written.
+func TestPublishStreamFullDuplex(t *testing.T) {
+	setup()
+
+	mr := mux.NewRouter()
+	configureRouter(mr, &countingProducer{})
+	srv := httptest.NewServer(mr)
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequest("POST", srv.URL+"/events/stream", pr)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	respc := make(chan *http.Response)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		respc <- resp
+	}()
+
+	fmt.Fprintln(pw, `{"cluster": "kafka-cl01", "topic": "orders", "data": {}}`)
+
+	resp := <-respc
+	defer resp.Body.Close()
+	results := bufio.NewScanner(resp.Body)
+
+	for line := 1; line <= 3; line++ {
+		assert.True(t, results.Scan())
+
+		var sr streamResult
+		assert.Nil(t, json.Unmarshal(results.Bytes(), &sr))
+		assert.Equal(t, line, sr.Line)
+
+		if line < 3 {
+			fmt.Fprintln(pw, `{"cluster": "kafka-cl01", "topic": "orders", "data": {}}`)
+		}
+	}
+
+	pw.Close()
+	assert.False(t, results.Scan())
+}
+
+// the server's timeouts apply to every line rather than the whole stream.
+func TestPublishStreamTimeout(t *testing.T) {
+	setup()
+
+	Config.ReadTimeoutSeconds = 1
+	Config.WriteTimeoutSeconds = 1
+	defer func() {
+		Config.ReadTimeoutSeconds = 0
+		Config.WriteTimeoutSeconds = 0
+	}()
+
+	mr := mux.NewRouter()
+	configureRouter(mr, &countingProducer{})
+	srv := httptest.NewUnstartedServer(mr)
+	srv.Config.ReadTimeout = time.Second
+	srv.Config.WriteTimeout = time.Second
+	srv.Start()
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequest("POST", srv.URL+"/events/stream", pr)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	go func() {
+		for i := 0; i < 4; i++ {
+			fmt.Fprintln(pw, `{"cluster": "kafka-cl01", "topic": "orders", "data": {}}`)
+			time.Sleep(time.Millisecond * 600)
+		}
+		pw.Close()
+	}()
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	results := bufio.NewScanner(resp.Body)
+	lines := 0
+
+	for results.Scan() {
+		var sr streamResult
+		assert.Nil(t, json.Unmarshal(results.Bytes(), &sr))
+		assert.Equal(t, http.StatusOK, sr.Status)
+		lines++
+	}
+
+	assert.Nil(t, results.Err())
+	assert.Equal(t, 4, lines)
+}
+
+func TestStreamWorker(t *testing.T) {
+	assert.Equal(t, streamWorker("a", 1, 16), streamWorker("a", 2, 16))
+	assert.Equal(t, 1, streamWorker(nil, 1, 16))
+	assert.Equal(t, 2, streamWorker(nil, 18, 16))
+}
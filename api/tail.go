@@ -0,0 +1,328 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+)
+
+const (
+	defaultTailMaxMessages = 100
+	defaultTailMaxSeconds  = 60
+	defaultTailRecent      = 10
+	tailPollMs             = 100
+	tailMetadataTimeoutMs  = 10000
+	tailKeepAlive          = 15 * time.Second
+)
+
+// tailConfig enables GET /clusters/{cluster}/topics/{topic}/tail for debugging.
+type tailConfig struct {
+	Enabled bool `json:"enabled"`
+	// MaxMessages and MaxSeconds are the defaults and upper limits of the limit and timeout parameters.
+	MaxMessages int `json:"maxMessages,omitempty"`
+	MaxSeconds  int `json:"maxSeconds,omitempty"`
+	// Topics restricts the topics that may be tailed, all topics when empty.
+	Topics []string `json:"topics,omitempty"`
+}
+
+func tailMaxMessages() int {
+	if Config.Tail.MaxMessages <= 0 {
+		return defaultTailMaxMessages
+	}
+
+	return Config.Tail.MaxMessages
+}
+
+func tailMaxSeconds() int {
+	if Config.Tail.MaxSeconds <= 0 {
+		return defaultTailMaxSeconds
+	}
+
+	return Config.Tail.MaxSeconds
+}
+
+// tailConsumer is the part of *kafka.Consumer used to tail a topic.
+type tailConsumer interface {
+	GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error)
+	QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (low, high int64, err error)
+	Assign(partitions []kafka.TopicPartition) error
+	Poll(timeoutMs int) kafka.Event
+	Close() error
+}
+
+// newTailConsumer creates an ephemeral consumer, partitions are assigned directly so it never
+// joins the group or commits offsets.
+var newTailConsumer = func(cluster string) (tailConsumer, error) {
+	cfg, err := kafkaClusterLookup(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+
+	kcm := clientConfigMap(cfg)
+	kcm["group.id"] = fmt.Sprintf("kafka-producer-proxy-tail-%s", hostname)
+	kcm["enable.auto.commit"] = false
+	kcm["enable.auto.offset.store"] = false
+	kcm["auto.offset.reset"] = "latest"
+
+	return kafka.NewConsumer(&kcm)
+}
+
+type tailOptions struct {
+	// Recent is the number of messages per partition before the end to start from.
+	Recent  int
+	Limit   int
+	Timeout time.Duration
+}
+
+// tailMessage is the data of each SSE message event.
+type tailMessage struct {
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	Key       interface{}       `json:"key"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Value     interface{}       `json:"value"`
+}
+
+// TailTopic streams recent and new messages of the topic as Server-Sent Events until the limit
+// or timeout is reached.
+func (rh router) TailTopic(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	vars := mux.Vars(r)
+	cluster, topic := vars["cluster"], vars["topic"]
+
+	if re := checkTail(r, topic); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	opts, re := parseTailOptions(r.URL.Query())
+	if re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	consumer, err := newTailConsumer(cluster)
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+	defer consumer.Close()
+
+	assignment, err := tailAssignment(consumer, topic, opts.Recent)
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	if err := consumer.Assign(assignment); err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	// the tail may outlive the server's write timeout
+	deadline := time.Now().Add(opts.Timeout)
+	if err := http.NewResponseController(w).SetWriteDeadline(deadline.Add(tailKeepAlive)); err != nil {
+		log.Debug().Err(err).Msg("write deadline not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	count := 0
+	reason := "timeout"
+	keepAlive := time.Now().Add(tailKeepAlive)
+
+	for time.Now().Before(deadline) {
+		if count >= opts.Limit {
+			reason = "limit"
+			break
+		}
+
+		if r.Context().Err() != nil {
+			return
+		}
+
+		switch e := consumer.Poll(tailPollMs).(type) {
+		case *kafka.Message:
+			writeSSE(w, "message", fmt.Sprintf("%d-%d", e.TopicPartition.Partition, e.TopicPartition.Offset), newTailMessage(e))
+			flush()
+			count++
+		case kafka.Error:
+			log.Warn().Err(e).Msg("tail consumer error")
+
+			if e.IsFatal() {
+				writeSSE(w, "error", "", map[string]string{"message": "consumer failed"})
+				flush()
+				return
+			}
+		}
+
+		if time.Now().After(keepAlive) {
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flush()
+			keepAlive = time.Now().Add(tailKeepAlive)
+		}
+	}
+
+	writeSSE(w, "end", "", map[string]interface{}{"reason": reason, "count": count})
+	flush()
+}
+
+// checkTail requires tailing to be enabled, a valid API token, even if enableApiAuth is false,
+// and the topic to be allowed.
+func checkTail(r *http.Request, topic string) *apiError {
+	if !Config.Tail.Enabled {
+		return newAPIError(http.StatusNotFound, codeNotEnabled, "tailing topics is not enabled", false, nil)
+	}
+
+	if err := requireAPIToken(r); err != nil {
+		return err
+	}
+
+	if fields := validateTopic("topic", topic); len(fields) > 0 {
+		return validationError(fields)
+	}
+
+	if len(Config.Tail.Topics) > 0 && !contains(Config.Tail.Topics, topic) {
+		return newAPIError(http.StatusForbidden, codeForbidden, fmt.Sprintf("topic '%s' may not be tailed", topic), false, nil)
+	}
+
+	return nil
+}
+
+// parseTailOptions reads the recent, limit and timeout (seconds) query parameters.
+func parseTailOptions(query url.Values) (tailOptions, *apiError) {
+	opts := tailOptions{
+		Recent:  defaultTailRecent,
+		Limit:   tailMaxMessages(),
+		Timeout: time.Duration(tailMaxSeconds()) * time.Second,
+	}
+
+	fields := []fieldError{}
+
+	parse := func(name string, max int, set func(int)) {
+		v := query.Get(name)
+		if len(v) == 0 {
+			return
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (max > 0 && n > max) {
+			fields = append(fields, fieldError{name, "out_of_range", fmt.Sprintf("%s must be a number between 0 and %d", name, max)})
+			return
+		}
+
+		set(n)
+	}
+
+	parse("recent", tailMaxMessages(), func(n int) { opts.Recent = n })
+	parse("limit", tailMaxMessages(), func(n int) { opts.Limit = n })
+	parse("timeout", tailMaxSeconds(), func(n int) { opts.Timeout = time.Duration(n) * time.Second })
+
+	if len(fields) > 0 {
+		return opts, validationError(fields)
+	}
+
+	return opts, nil
+}
+
+// tailAssignment starts every partition of the topic the given number of messages before its end.
+func tailAssignment(consumer tailConsumer, topic string, recent int) ([]kafka.TopicPartition, error) {
+	md, err := consumer.GetMetadata(&topic, false, tailMetadataTimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+
+	tm, ok := md.Topics[topic]
+	if !ok || tm.Error.Code() == kafka.ErrUnknownTopicOrPart || tm.Error.Code() == kafka.ErrUnknownTopic {
+		return nil, newTopicNotFoundError(topic, tm.Error)
+	} else if tm.Error.Code() != kafka.ErrNoError {
+		return nil, tm.Error
+	}
+
+	assignment := make([]kafka.TopicPartition, 0, len(tm.Partitions))
+	for _, p := range tm.Partitions {
+		low, high, err := consumer.QueryWatermarkOffsets(topic, p.ID, tailMetadataTimeoutMs)
+		if err != nil {
+			return nil, err
+		}
+
+		start := high - int64(recent)
+		if start < low {
+			start = low
+		}
+
+		assignment = append(assignment, kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: p.ID,
+			Offset:    kafka.Offset(start),
+		})
+	}
+
+	return assignment, nil
+}
+
+func newTailMessage(m *kafka.Message) tailMessage {
+	tm := tailMessage{
+		Partition: m.TopicPartition.Partition,
+		Offset:    int64(m.TopicPartition.Offset),
+		Timestamp: m.Timestamp,
+		Key:       tailValue(m.Key),
+		Value:     tailValue(m.Value),
+	}
+
+	for _, h := range m.Headers {
+		if tm.Headers == nil {
+			tm.Headers = map[string]string{}
+		}
+
+		tm.Headers[h.Key] = string(h.Value)
+	}
+
+	return tm
+}
+
+// tailValue returns JSON as is, anything else as a string.
+func tailValue(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+
+	if json.Valid(b) {
+		return json.RawMessage(b)
+	}
+
+	return string(b)
+}
+
+// writeSSE writes a Server-Sent Event with the JSON of data.
+func writeSSE(w http.ResponseWriter, event, id string, data interface{}) {
+	b, _ := json.Marshal(data)
+
+	if len(id) > 0 {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTailConsumer struct {
+	partitions []int32
+	high       int64
+	messages   []*kafka.Message
+	assigned   []kafka.TopicPartition
+	closed     bool
+}
+
+func (c *fakeTailConsumer) GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error) {
+	md := &kafka.Metadata{Topics: map[string]kafka.TopicMetadata{}}
+
+	if *topic == "orders" {
+		tm := kafka.TopicMetadata{Topic: *topic}
+		for _, p := range c.partitions {
+			tm.Partitions = append(tm.Partitions, kafka.PartitionMetadata{ID: p})
+		}
+		md.Topics[*topic] = tm
+	}
+
+	return md, nil
+}
+
+func (c *fakeTailConsumer) QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (int64, int64, error) {
+	return 0, c.high, nil
+}
+
+func (c *fakeTailConsumer) Assign(partitions []kafka.TopicPartition) error {
+	c.assigned = partitions
+	return nil
+}
+
+func (c *fakeTailConsumer) Poll(timeoutMs int) kafka.Event {
+	if len(c.messages) == 0 {
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+
+	m := c.messages[0]
+	c.messages = c.messages[1:]
+
+	return m
+}
+
+func (c *fakeTailConsumer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func tailRequest(path, token string) *http.Request {
+	req := httptest.NewRequest("GET", path, nil)
+	if len(token) > 0 {
+		req.Header.Set("X-API-TOKEN", token)
+	}
+
+	return req
+}
+
+func TestTailTopic(t *testing.T) {
+	setup()
+	Config.Tail = tailConfig{Enabled: true}
+	defer func() {
+		Config.Tail = tailConfig{}
+	}()
+
+	topic := "orders"
+	consumer := &fakeTailConsumer{
+		partitions: []int32{0, 1},
+		high:       5,
+		messages: []*kafka.Message{
+			{
+				TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: 3},
+				Key:            []byte(`"order-1"`),
+				Value:          []byte(`{"id":1}`),
+				Headers:        []kafka.Header{{Key: "trace", Value: []byte("abc")}},
+			},
+			{
+				TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 4},
+				Value:          []byte("not json"),
+			},
+			{
+				TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 5},
+			},
+		},
+	}
+
+	defer func(f func(string) (tailConsumer, error)) {
+		newTailConsumer = f
+	}(newTailConsumer)
+	newTailConsumer = func(string) (tailConsumer, error) { return consumer, nil }
+
+	mr := mux.NewRouter()
+	configureRouter(mr, &countingProducer{})
+
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, tailRequest("/clusters/kafka-cl01/topics/orders/tail?recent=2&limit=2", Secrets.APIToken))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.True(t, consumer.closed)
+	assert.Len(t, consumer.assigned, 2)
+	assert.Equal(t, kafka.Offset(3), consumer.assigned[1].Offset)
+
+	events := []string{}
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); len(line) > 0 {
+			events = append(events, line)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"id: 0-3",
+		"event: message",
+		`data: {"partition":0,"offset":3,"timestamp":"0001-01-01T00:00:00Z","key":"order-1","headers":{"trace":"abc"},"value":{"id":1}}`,
+		"id: 1-4",
+		"event: message",
+		`data: {"partition":1,"offset":4,"timestamp":"0001-01-01T00:00:00Z","key":null,"value":"not json"}`,
+		"event: end",
+		`data: {"count":2,"reason":"limit"}`,
+	}, events)
+}
+
+func TestTailTopicErrors(t *testing.T) {
+	setup()
+	defer func(f func(string) (tailConsumer, error)) {
+		Config.Tail = tailConfig{}
+		newTailConsumer = f
+	}(newTailConsumer)
+	newTailConsumer = func(string) (tailConsumer, error) { return &fakeTailConsumer{}, nil }
+
+	mr := mux.NewRouter()
+	configureRouter(mr, &countingProducer{})
+
+	tests := []struct {
+		name   string
+		config tailConfig
+		path   string
+		token  string
+		status int
+	}{
+		{"disabled", tailConfig{}, "/clusters/kafka-cl01/topics/orders/tail", "TestApiToken", http.StatusNotFound},
+		{"no token", tailConfig{Enabled: true}, "/clusters/kafka-cl01/topics/orders/tail", "", http.StatusUnauthorized},
+		{"topic not allowed", tailConfig{Enabled: true, Topics: []string{"payments"}}, "/clusters/kafka-cl01/topics/orders/tail", "TestApiToken", http.StatusForbidden},
+		{"limit too large", tailConfig{Enabled: true}, "/clusters/kafka-cl01/topics/orders/tail?limit=1000", "TestApiToken", http.StatusBadRequest},
+		{"unknown topic", tailConfig{Enabled: true}, "/clusters/kafka-cl01/topics/payments/tail", "TestApiToken", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Config.Tail = tt.config
+
+			w := httptest.NewRecorder()
+			mr.ServeHTTP(w, tailRequest(tt.path, tt.token))
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestParseTailOptions(t *testing.T) {
+	opts, re := parseTailOptions(url.Values{})
+	assert.Nil(t, re)
+	assert.Equal(t, tailOptions{Recent: 10, Limit: 100, Timeout: time.Minute}, opts)
+
+	opts, re = parseTailOptions(url.Values{"recent": {"0"}, "limit": {"5"}, "timeout": {"2"}})
+	assert.Nil(t, re)
+	assert.Equal(t, tailOptions{Recent: 0, Limit: 5, Timeout: 2 * time.Second}, opts)
+
+	_, re = parseTailOptions(url.Values{"recent": {"-1"}, "timeout": {"x"}})
+	assert.Equal(t, 2, len(re.Fields))
+	assert.True(t, strings.Contains(re.Fields[1].Message, "timeout"))
+}
@@ -0,0 +1,388 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+)
+
+const (
+	topicAdminTimeout   = 30 * time.Second
+	fieldCodePolicy     = "policy_violation"
+	retentionMsConfig   = "retention.ms"
+	infiniteRetentionMs = -1
+)
+
+// topicAdminConfig enables the /admin/clusters/{cluster}/topics routes and constrains the changes they make.
+type topicAdminConfig struct {
+	Enabled bool `json:"enabled"`
+	// NamePattern is a regular expression the names of new topics must match.
+	NamePattern string `json:"namePattern,omitempty"`
+	// MaxPartitions limits the partitions of new and grown topics, unlimited when not set.
+	MaxPartitions int `json:"maxPartitions,omitempty"`
+	// MinRetentionMs and MaxRetentionMs bound retention.ms, unbounded when not set.
+	MinRetentionMs int64 `json:"minRetentionMs,omitempty"`
+	MaxRetentionMs int64 `json:"maxRetentionMs,omitempty"`
+}
+
+// topicPolicy checks topic changes against the topicAdmin constraints.
+type topicPolicy struct {
+	config      topicAdminConfig
+	namePattern *regexp.Regexp
+}
+
+// topicAdminPolicy is the policy shared by the admin routes, set when the server starts.
+var topicAdminPolicy = &topicPolicy{}
+
+// newTopicPolicy returns an error if the name pattern doesn't compile or the retention range is empty.
+func newTopicPolicy(config topicAdminConfig) (*topicPolicy, error) {
+	p := &topicPolicy{config: config}
+
+	if len(config.NamePattern) > 0 {
+		re, err := regexp.Compile(config.NamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("topicAdmin.namePattern is invalid: %w", err)
+		}
+
+		p.namePattern = re
+	}
+
+	if config.MaxRetentionMs > 0 && config.MinRetentionMs > config.MaxRetentionMs {
+		return nil, fmt.Errorf("topicAdmin.minRetentionMs must not be greater than maxRetentionMs")
+	}
+
+	return p, nil
+}
+
+func (p *topicPolicy) checkName(name string) []fieldError {
+	if fields := validateTopic("name", name); len(fields) > 0 {
+		return fields
+	}
+
+	if p.namePattern != nil && !p.namePattern.MatchString(name) {
+		return []fieldError{{"name", fieldCodePolicy, fmt.Sprintf("name must match %s", p.config.NamePattern)}}
+	}
+
+	return nil
+}
+
+func (p *topicPolicy) checkPartitions(field string, partitions int) []fieldError {
+	if partitions <= 0 {
+		return []fieldError{{field, fieldCodeOutOfRange, fmt.Sprintf("%s must be greater than 0", field)}}
+	}
+
+	if p.config.MaxPartitions > 0 && partitions > p.config.MaxPartitions {
+		return []fieldError{{field, fieldCodePolicy, fmt.Sprintf("%s must not be greater than %d", field, p.config.MaxPartitions)}}
+	}
+
+	return nil
+}
+
+// checkConfigs checks retention.ms is within the allowed range, -1 (infinite) exceeds any maximum.
+func (p *topicPolicy) checkConfigs(configs map[string]string) []fieldError {
+	value, ok := configs[retentionMsConfig]
+	if !ok {
+		return nil
+	}
+
+	field := fmt.Sprintf("configs.%s", retentionMsConfig)
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms < infiniteRetentionMs {
+		return []fieldError{{field, fieldCodeOutOfRange, fmt.Sprintf("%s must be a number of milliseconds or -1", retentionMsConfig)}}
+	}
+
+	if p.config.MaxRetentionMs > 0 && (ms == infiniteRetentionMs || ms > p.config.MaxRetentionMs) {
+		return []fieldError{{field, fieldCodePolicy, fmt.Sprintf("%s must not be greater than %d", retentionMsConfig, p.config.MaxRetentionMs)}}
+	}
+
+	if ms != infiniteRetentionMs && ms < p.config.MinRetentionMs {
+		return []fieldError{{field, fieldCodePolicy, fmt.Sprintf("%s must not be less than %d", retentionMsConfig, p.config.MinRetentionMs)}}
+	}
+
+	return nil
+}
+
+type createTopicRequest struct {
+	Name       string `json:"name"`
+	Partitions int    `json:"partitions"`
+	// ReplicationFactor defaults to the broker's default.replication.factor.
+	ReplicationFactor int               `json:"replicationFactor,omitempty"`
+	Configs           map[string]string `json:"configs,omitempty"`
+}
+
+type createPartitionsRequest struct {
+	// Partitions is the new partition count of the topic.
+	Partitions int `json:"partitions"`
+}
+
+type alterTopicConfigsRequest struct {
+	Configs map[string]string `json:"configs"`
+}
+
+type topicAdminResponse struct {
+	Message string `json:"message"`
+	Cluster string `json:"cluster"`
+	Topic   string `json:"topic"`
+	DryRun  bool   `json:"dryRun"`
+}
+
+// CreateTopic creates a topic, or only validates it with ?dryRun=true.
+func (rh router) CreateTopic(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	cluster := mux.Vars(r)["cluster"]
+
+	if re := checkTopicAdmin(r); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	var req createTopicRequest
+	if re := decodeBody(w, r, &req); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	fields := topicAdminPolicy.checkName(req.Name)
+	fields = append(fields, topicAdminPolicy.checkPartitions("partitions", req.Partitions)...)
+	fields = append(fields, topicAdminPolicy.checkConfigs(req.Configs)...)
+
+	if req.ReplicationFactor < 0 {
+		fields = append(fields, fieldError{"replicationFactor", fieldCodeOutOfRange, "replicationFactor must be greater than 0"})
+	}
+
+	if len(fields) > 0 {
+		writeErrorResponse(w, log, "", validationError(fields))
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	err := withTopicAdmin(r.Context(), cluster, func(ctx context.Context, ac clusterAdmin) error {
+		results, err := ac.CreateTopics(ctx, []kafka.TopicSpecification{{
+			Topic:             req.Name,
+			NumPartitions:     req.Partitions,
+			ReplicationFactor: req.ReplicationFactor,
+			Config:            req.Configs,
+		}}, kafka.SetAdminValidateOnly(dryRun))
+
+		if err != nil {
+			return err
+		}
+
+		return topicResultsError(results)
+	})
+
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	log.Info().Bool("dryRun", dryRun).Msgf("topic %s created on cluster %s", req.Name, cluster)
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	} else {
+		introspectionCache.Delete(metadataCacheKey(cluster))
+	}
+
+	writeJSON(w, log, status, topicAdminResponse{"topic created", cluster, req.Name, dryRun})
+}
+
+// CreatePartitions grows the topic to the requested number of partitions.
+func (rh router) CreatePartitions(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	vars := mux.Vars(r)
+	cluster, topic := vars["cluster"], vars["topic"]
+
+	if re := checkTopicAdmin(r); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	var req createPartitionsRequest
+	if re := decodeBody(w, r, &req); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	fields := validateTopic("topic", topic)
+	fields = append(fields, topicAdminPolicy.checkPartitions("partitions", req.Partitions)...)
+
+	if len(fields) > 0 {
+		writeErrorResponse(w, log, "", validationError(fields))
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	err := withTopicAdmin(r.Context(), cluster, func(ctx context.Context, ac clusterAdmin) error {
+		results, err := ac.CreatePartitions(ctx, []kafka.PartitionsSpecification{{
+			Topic:      topic,
+			IncreaseTo: req.Partitions,
+		}}, kafka.SetAdminValidateOnly(dryRun))
+
+		if err != nil {
+			return err
+		}
+
+		return topicResultsError(results)
+	})
+
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	log.Info().Bool("dryRun", dryRun).Msgf("topic %s of cluster %s increased to %d partitions", topic, cluster, req.Partitions)
+
+	if !dryRun {
+		introspectionCache.Delete(metadataCacheKey(cluster))
+	}
+
+	writeJSON(w, log, http.StatusOK, topicAdminResponse{fmt.Sprintf("topic increased to %d partitions", req.Partitions), cluster, topic, dryRun})
+}
+
+// AlterTopicConfigs sets the configs of the topic, keeping the other configs set on the topic.
+func (rh router) AlterTopicConfigs(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+	vars := mux.Vars(r)
+	cluster, topic := vars["cluster"], vars["topic"]
+
+	if re := checkTopicAdmin(r); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	var req alterTopicConfigsRequest
+	if re := decodeBody(w, r, &req); re != nil {
+		writeErrorResponse(w, log, "", re)
+		return
+	}
+
+	fields := validateTopic("topic", topic)
+	fields = append(fields, topicAdminPolicy.checkConfigs(req.Configs)...)
+	if len(req.Configs) == 0 {
+		fields = append(fields, fieldError{"configs", fieldCodeRequired, "configs is required"})
+	}
+
+	if len(fields) > 0 {
+		writeErrorResponse(w, log, "", validationError(fields))
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	err := withTopicAdmin(r.Context(), cluster, func(ctx context.Context, ac clusterAdmin) error {
+		resource := kafka.ConfigResource{Type: kafka.ResourceTopic, Name: topic}
+
+		// AlterConfigs replaces every config of the topic, so the ones set on it are sent along
+		current, err := ac.DescribeConfigs(ctx, []kafka.ConfigResource{resource})
+		if err != nil {
+			return err
+		}
+
+		configs := map[string]string{}
+
+		for _, result := range current {
+			if result.Error.Code() != kafka.ErrNoError {
+				return result.Error
+			}
+
+			for name, entry := range result.Config {
+				if entry.Source != kafka.ConfigSourceDynamicTopic {
+					continue
+				}
+
+				// the value of a sensitive config isn't returned, so it would be reset
+				if entry.IsSensitive {
+					return newAPIError(http.StatusForbidden, codeForbidden,
+						fmt.Sprintf("topic %s has the sensitive config %s, which can't be kept when altering its configs", topic, name), false, nil)
+				}
+
+				configs[name] = entry.Value
+			}
+		}
+
+		for name, value := range req.Configs {
+			configs[name] = value
+		}
+
+		resource.Config = kafka.StringMapToConfigEntries(configs, kafka.AlterOperationSet)
+
+		results, err := ac.AlterConfigs(ctx, []kafka.ConfigResource{resource}, kafka.SetAdminValidateOnly(dryRun))
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			if result.Error.Code() != kafka.ErrNoError {
+				return result.Error
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		writeErrorResponse(w, log, "", err)
+		return
+	}
+
+	log.Info().Bool("dryRun", dryRun).Msgf("configs of topic %s of cluster %s altered", topic, cluster)
+
+	if !dryRun {
+		introspectionCache.Delete(configsCacheKey(cluster, topic))
+	}
+
+	writeJSON(w, log, http.StatusOK, topicAdminResponse{"topic configs altered", cluster, topic, dryRun})
+}
+
+// checkTopicAdmin requires the admin routes to be enabled and a valid API token, even if enableApiAuth is false.
+func checkTopicAdmin(r *http.Request) *apiError {
+	if !Config.TopicAdmin.Enabled {
+		return newAPIError(http.StatusNotFound, codeNotEnabled, "topic administration is not enabled", false, nil)
+	}
+
+	if err := requireAPIToken(r); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// withTopicAdmin calls f with the admin client of the cluster and a timeout for the request.
+func withTopicAdmin(ctx context.Context, cluster string, f func(context.Context, clusterAdmin) error) error {
+	ac, err := newClusterAdmin(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, topicAdminTimeout)
+	defer cancel()
+
+	return f(ctx, ac)
+}
+
+// topicResultsError returns the first error of the results.
+func topicResultsError(results []kafka.TopicResult) error {
+	for _, result := range results {
+		if result.Error.Code() == kafka.ErrUnknownTopicOrPart || result.Error.Code() == kafka.ErrUnknownTopic {
+			return newTopicNotFoundError(result.Topic, result.Error)
+		}
+
+		if result.Error.Code() != kafka.ErrNoError {
+			return result.Error
+		}
+	}
+
+	return nil
+}
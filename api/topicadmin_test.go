@@ -0,0 +1,160 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func topicAdminRequest(mr *mux.Router, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if len(token) > 0 {
+		req.Header.Set("X-API-TOKEN", token)
+	}
+
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, req)
+
+	return w
+}
+
+func enableTopicAdmin(t *testing.T, config topicAdminConfig) {
+	config.Enabled = true
+	Config.TopicAdmin = config
+
+	p, err := newTopicPolicy(config)
+	assert.Nil(t, err)
+
+	topicAdminPolicy = p
+}
+
+func TestCreateTopic(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	mr := introspectionRouter(t, admin)
+	enableTopicAdmin(t, topicAdminConfig{})
+
+	w := topicAdminRequest(mr, "POST", "/admin/clusters/kafka-cl01/topics", `{"name":"invoices","partitions":6,"configs":{"retention.ms":"86400000"}}`, Secrets.APIToken)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"message":"topic created","cluster":"kafka-cl01","topic":"invoices","dryRun":false}`, w.Body.String())
+	assert.Equal(t, []kafka.TopicSpecification{{Topic: "invoices", NumPartitions: 6, Config: map[string]string{"retention.ms": "86400000"}}}, admin.created)
+	assert.False(t, admin.validateOnly)
+
+	w = topicAdminRequest(mr, "POST", "/admin/clusters/kafka-cl01/topics?dryRun=true", `{"name":"invoices","partitions":6}`, Secrets.APIToken)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, admin.validateOnly)
+
+	w = topicAdminRequest(mr, "POST", "/admin/clusters/kafka-cl01/topics", `{"name":"orders","partitions":6}`, Secrets.APIToken)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), codeTopicExists)
+}
+
+func TestCreateTopicPolicy(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	mr := introspectionRouter(t, admin)
+	enableTopicAdmin(t, topicAdminConfig{
+		NamePattern:    `^[a-z]+\.[a-z]+$`,
+		MaxPartitions:  12,
+		MinRetentionMs: 3600000,
+		MaxRetentionMs: 604800000,
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+	}{
+		{"valid", `{"name":"billing.invoices","partitions":12,"configs":{"retention.ms":"3600000"}}`, nil},
+		{"naming convention", `{"name":"invoices","partitions":1}`, []string{"name"}},
+		{"too many partitions", `{"name":"billing.invoices","partitions":13}`, []string{"partitions"}},
+		{"no partitions", `{"name":"billing.invoices"}`, []string{"partitions"}},
+		{"retention too long", `{"name":"billing.invoices","partitions":1,"configs":{"retention.ms":"-1"}}`, []string{"configs.retention.ms"}},
+		{"retention too short", `{"name":"billing.invoices","partitions":1,"configs":{"retention.ms":"1000"}}`, []string{"configs.retention.ms"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := topicAdminRequest(mr, "POST", "/admin/clusters/kafka-cl01/topics?dryRun=true", tt.body, Secrets.APIToken)
+
+			if len(tt.fields) == 0 {
+				assert.Equal(t, http.StatusOK, w.Code)
+				return
+			}
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			for _, f := range tt.fields {
+				assert.Contains(t, w.Body.String(), `"field":"`+f+`"`)
+			}
+		})
+	}
+
+	assert.Len(t, admin.created, 1)
+}
+
+func TestCreatePartitions(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	mr := introspectionRouter(t, admin)
+	enableTopicAdmin(t, topicAdminConfig{MaxPartitions: 12})
+
+	w := topicAdminRequest(mr, "POST", "/admin/clusters/kafka-cl01/topics/orders/partitions", `{"partitions":24}`, Secrets.APIToken)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = topicAdminRequest(mr, "POST", "/admin/clusters/kafka-cl01/topics/orders/partitions", `{"partitions":12}`, Secrets.APIToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []kafka.PartitionsSpecification{{Topic: "orders", IncreaseTo: 12}}, admin.partitions)
+}
+
+func TestAlterTopicConfigs(t *testing.T) {
+	admin := &fakeClusterAdmin{}
+	mr := introspectionRouter(t, admin)
+	enableTopicAdmin(t, topicAdminConfig{MaxRetentionMs: 604800000})
+
+	w := topicAdminRequest(mr, "PATCH", "/admin/clusters/kafka-cl01/topics/orders/configs", `{"configs":{"retention.ms":"-1"}}`, Secrets.APIToken)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = topicAdminRequest(mr, "PATCH", "/admin/clusters/kafka-cl01/topics/orders/configs?dryRun=true", `{"configs":{"retention.ms":"86400000"}}`, Secrets.APIToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, admin.validateOnly)
+
+	// the configs set on the topic are kept
+	assert.ElementsMatch(t, []kafka.ConfigEntry{
+		{Name: "cleanup.policy", Value: "compact", Operation: kafka.AlterOperationSet},
+		{Name: "retention.ms", Value: "86400000", Operation: kafka.AlterOperationSet},
+	}, admin.altered[0].Config)
+
+	// sensitive configs can't be kept, they would be reset
+	w = topicAdminRequest(mr, "PATCH", "/admin/clusters/kafka-cl01/topics/payments/configs", `{"configs":{"retention.ms":"86400000"}}`, Secrets.APIToken)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Len(t, admin.altered, 1)
+
+	w = topicAdminRequest(mr, "PATCH", "/admin/clusters/kafka-cl01/topics/orders$/configs", `{"configs":{"retention.ms":"86400000"}}`, Secrets.APIToken)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"topic"`)
+}
+
+func TestTopicAdminAuth(t *testing.T) {
+	mr := introspectionRouter(t, &fakeClusterAdmin{})
+
+	w := topicAdminRequest(mr, "POST", "/admin/clusters/kafka-cl01/topics", `{"name":"invoices","partitions":1}`, Secrets.APIToken)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	enableTopicAdmin(t, topicAdminConfig{})
+
+	w = topicAdminRequest(mr, "POST", "/admin/clusters/kafka-cl01/topics", `{"name":"invoices","partitions":1}`, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestNewTopicPolicy(t *testing.T) {
+	_, err := newTopicPolicy(topicAdminConfig{NamePattern: "("})
+	assert.Error(t, err)
+
+	_, err = newTopicPolicy(topicAdminConfig{MinRetentionMs: 10, MaxRetentionMs: 5})
+	assert.Error(t, err)
+}
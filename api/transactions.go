@@ -0,0 +1,357 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultTransactionPoolSize       = 2
+	defaultTransactionTimeoutSeconds = 30
+)
+
+// transactionalPools holds the transactional producer pool per cluster, nil unless transactions are enabled.
+var transactionalPools map[string]*transactionalPool
+
+type transactionsConfig struct {
+	Enabled        bool `json:"enabled"`
+	PoolSize       int  `json:"poolSize,omitempty"`
+	TimeoutSeconds int  `json:"timeoutSeconds,omitempty"`
+}
+
+// TransactionOptions .
+type TransactionOptions struct {
+	Context context.Context
+	Log     *zerolog.Logger
+	Cluster string
+	Events  []TransactionEvent
+	// Principal identifies the caller, used by the transformation pipeline.
+	Principal string
+}
+
+// TransactionEvent is a single message of a transaction.
+type TransactionEvent struct {
+	Topic string                 `json:"topic"`
+	Key   interface{}            `json:"key"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+// TransactionResult .
+type TransactionResult struct {
+	Messages []string
+	Error    error
+}
+
+// transactionalPool is a fixed size pool of transactional producers for a cluster. Each slot has its
+// own transactional.id and the producer is created on first use since InitTransactions requires
+// the cluster to be reachable.
+type transactionalPool struct {
+	cluster string
+	slots   chan *transactionalSlot
+}
+
+type transactionalSlot struct {
+	id       string
+	instance transactionalProducer
+}
+
+// transactionalProducer is the part of the kafka.Producer used by transactions.
+type transactionalProducer interface {
+	BeginTransaction() error
+	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+	CommitTransaction(ctx context.Context) error
+	AbortTransaction(ctx context.Context) error
+	Close()
+}
+
+// newTransactionalPools creates a pool for every configured cluster.
+func newTransactionalPools() map[string]*transactionalPool {
+	size := Config.Transactions.PoolSize
+	if size <= 0 {
+		size = defaultTransactionPoolSize
+	}
+
+	hostname, _ := os.Hostname()
+	pools := map[string]*transactionalPool{}
+
+	for _, cluster := range Config.KafkaBrokerGroups {
+		tp := &transactionalPool{
+			cluster: cluster,
+			slots:   make(chan *transactionalSlot, size),
+		}
+
+		for i := 0; i < size; i++ {
+			tp.slots <- &transactionalSlot{
+				id: fmt.Sprintf("kafka-producer-proxy-%s-%s-%d", hostname, cluster, i),
+			}
+		}
+
+		pools[cluster] = tp
+	}
+
+	return pools
+}
+
+func transactionTimeout() time.Duration {
+	timeout := Config.Transactions.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultTransactionTimeoutSeconds
+	}
+
+	return time.Second * time.Duration(timeout)
+}
+
+// lookupTransactionalPool returns the pool for the cluster.
+func lookupTransactionalPool(cluster string) (*transactionalPool, error) {
+	if transactionalPools == nil {
+		return nil, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    codeNotEnabled,
+			Message: "transactions are not enabled",
+		}
+	}
+
+	for name, tp := range transactionalPools {
+		if strings.EqualFold(name, cluster) {
+			return tp, nil
+		}
+	}
+
+	return nil, newClusterNotFoundError(cluster)
+}
+
+// acquire waits for a free slot, initializing its producer if needed.
+func (tp *transactionalPool) acquire(ctx context.Context) (*transactionalSlot, error) {
+	var slot *transactionalSlot
+
+	select {
+	case slot = <-tp.slots:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if slot.instance != nil {
+		return slot, nil
+	}
+
+	kcm, err := producerConfigMap(tp.cluster)
+	if err != nil {
+		tp.release(slot)
+		return nil, err
+	}
+
+	kcm["transactional.id"] = slot.id
+	kcm["transaction.timeout.ms"] = int(transactionTimeout() / time.Millisecond)
+
+	instance, err := kafka.NewProducer(&kcm)
+	if err != nil {
+		tp.release(slot)
+		return nil, err
+	}
+
+	if err := instance.InitTransactions(ctx); err != nil {
+		instance.Close()
+		tp.release(slot)
+		return nil, err
+	}
+
+	go watchEvents(tp.cluster, instance)
+
+	slot.instance = instance
+
+	return slot, nil
+}
+
+// release returns the slot to the pool.
+func (tp *transactionalPool) release(slot *transactionalSlot) {
+	tp.slots <- slot
+}
+
+// discard closes the producer of the slot so it is recreated on next use, required after a fatal error
+// or when the transaction couldn't be completed. The new producer fences the old transactional.id,
+// aborting its open transaction.
+func (tp *transactionalPool) discard(slot *transactionalSlot) {
+	if slot.instance != nil {
+		slot.instance.Close()
+		slot.instance = nil
+	}
+
+	tp.release(slot)
+}
+
+// ProduceTransaction publishes all the events in a single transaction, aborting if any fail.
+func (p producer) ProduceTransaction(options TransactionOptions) *TransactionResult {
+	tp, err := lookupTransactionalPool(options.Cluster)
+	if err != nil {
+		return &TransactionResult{Error: err}
+	}
+
+	events, err := prepareTransactionEvents(options)
+	if err != nil {
+		return &TransactionResult{Error: err}
+	}
+
+	ctx, cancel := context.WithTimeout(options.Context, transactionTimeout())
+	defer cancel()
+
+	slot, err := tp.acquire(ctx)
+	if err != nil {
+		return &TransactionResult{Error: err}
+	}
+
+	messages, completed, err := runTransaction(ctx, slot.instance, events)
+
+	var ke kafka.Error
+	if !completed || (errors.As(err, &ke) && ke.IsFatal()) {
+		tp.discard(slot)
+	} else {
+		tp.release(slot)
+	}
+
+	return &TransactionResult{
+		Messages: messages,
+		Error:    err,
+	}
+}
+
+// prepareTransactionEvents applies the durability restrictions, transforms and key rules to every
+// event, as for a single event. Transactions are produced with acks=all, so topics that don't allow
+// the all durability class are rejected.
+func prepareTransactionEvents(options TransactionOptions) ([]TransactionEvent, error) {
+	durability := ""
+	if Config.Durability.Enabled {
+		durability = durabilityAll
+	}
+
+	events := make([]TransactionEvent, 0, len(options.Events))
+
+	for i, e := range options.Events {
+		prepared, err := prepareProduceOptions(ProduceOptions{
+			Context:    options.Context,
+			Log:        options.Log,
+			Cluster:    options.Cluster,
+			Topic:      e.Topic,
+			Key:        e.Key,
+			Data:       e.Data,
+			Durability: durability,
+			Principal:  options.Principal,
+		})
+		if err != nil {
+			return nil, transactionEventError(i, err)
+		}
+
+		events = append(events, TransactionEvent{Topic: prepared.Topic, Key: prepared.Key, Data: prepared.Data})
+	}
+
+	return events, nil
+}
+
+// transactionEventError prefixes the fields of the error with the event they belong to.
+func transactionEventError(i int, err error) error {
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		return fmt.Errorf("event %d: %w", i, err)
+	}
+
+	e := *ae
+	e.Message = fmt.Sprintf("event %d: %s", i, ae.Message)
+	e.Fields = make([]fieldError, 0, len(ae.Fields))
+
+	for _, f := range ae.Fields {
+		f.Field = fmt.Sprintf("events[%d].%s", i, f.Field)
+		e.Fields = append(e.Fields, f)
+	}
+
+	return &e
+}
+
+// runTransaction produces the events within a transaction and waits for every delivery report
+// before committing. completed is false if the transaction may still be open, in which case the
+// producer can't be used for another one.
+func runTransaction(ctx context.Context, instance transactionalProducer, events []TransactionEvent) (messages []string, completed bool, err error) {
+	if err := instance.BeginTransaction(); err != nil {
+		return nil, true, err
+	}
+
+	deliveries := make(chan kafka.Event, len(events))
+
+	for i, e := range events {
+		msg, result := newMessage(e.Topic, e.Key, e.Data)
+		if result == nil {
+			result = &Result{Error: instance.Produce(msg, deliveries)}
+		}
+
+		if result.Error != nil {
+			return abortTransaction(instance, fmt.Errorf("event %d: %w", i, result.Error))
+		}
+	}
+
+	messages = make([]string, 0, len(events))
+
+	for range events {
+		select {
+		case e := <-deliveries:
+			m, ok := e.(*kafka.Message)
+			if !ok {
+				return abortTransaction(instance, fmt.Errorf("unexpected delivery event: %v", e))
+			}
+
+			if m.TopicPartition.Error != nil {
+				return abortTransaction(instance, m.TopicPartition.Error)
+			}
+
+			messages = append(messages, fmt.Sprintf("%v", m.TopicPartition))
+		case <-ctx.Done():
+			return abortTransaction(instance, ctx.Err())
+		}
+	}
+
+	// retriable errors may be retried by calling CommitTransaction again
+	for {
+		err = instance.CommitTransaction(ctx)
+		if err == nil {
+			return messages, true, nil
+		}
+
+		var ke kafka.Error
+		if !errors.As(err, &ke) {
+			return nil, false, err
+		}
+
+		if ke.TxnRequiresAbort() {
+			return abortTransaction(instance, err)
+		}
+
+		if !ke.IsRetriable() || ctx.Err() != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// abortTransaction aborts the current transaction, retrying until it succeeds or times out, and
+// returns the reason it was aborted. completed is false if the abort failed.
+func abortTransaction(instance transactionalProducer, reason error) ([]string, bool, error) {
+	// the transaction context may already be done, give the abort its own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), transactionTimeout())
+	defer cancel()
+
+	for {
+		err := instance.AbortTransaction(ctx)
+		if err == nil {
+			return nil, true, fmt.Errorf("transaction aborted: %w", reason)
+		}
+
+		var ke kafka.Error
+		if !errors.As(err, &ke) || !ke.IsRetriable() || ctx.Err() != nil {
+			return nil, false, fmt.Errorf("transaction aborted: %v, abort failed: %w", reason, err)
+		}
+	}
+}
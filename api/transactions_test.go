@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionalPools(t *testing.T) {
+	setup()
+
+	_, err := lookupTransactionalPool("kafka-cl01")
+	assert.NotNil(t, err)
+
+	Config.Transactions.PoolSize = 3
+	transactionalPools = newTransactionalPools()
+	defer func() {
+		Config.Transactions.PoolSize = 0
+		transactionalPools = nil
+	}()
+
+	tp, err := lookupTransactionalPool("KAFKA-CL01")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(tp.slots))
+
+	_, err = lookupTransactionalPool("kafka-cl02")
+	assert.NotNil(t, err)
+}
+
+func TestPublishTransaction(t *testing.T) {
+	setup()
+	cp := &countingProducer{}
+	rh := router{kp: cp}
+
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{"cluster": "kafka-cl01", "events": []}`))
+	w := httptest.NewRecorder()
+	rh.PublishTransaction(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	req = httptest.NewRequest("POST", "/transactions", strings.NewReader(`{"cluster": "kafka-cl01", "events": [
+		{"topic": "orders", "data": {"id": 1}},
+		{"topic": "payments", "data": {"id": 1}}
+	]}`))
+	w = httptest.NewRecorder()
+	rh.PublishTransaction(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, 2, cp.count)
+}
+
+// failingTransactionProducer fails every transaction with its error.
+type failingTransactionProducer struct {
+	countingProducer
+	err error
+}
+
+func (p *failingTransactionProducer) ProduceTransaction(options TransactionOptions) *TransactionResult {
+	return &TransactionResult{Error: p.err}
+}
+
+func TestPublishTransactionFailed(t *testing.T) {
+	setup()
+	rh := router{kp: &failingTransactionProducer{err: kafka.NewError(kafka.ErrUnknownTopicOrPart, "unknown", false)}}
+
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{"cluster": "kafka-cl01", "events": [
+		{"topic": "orders", "data": {"id": 1}}
+	]}`))
+	w := httptest.NewRecorder()
+	rh.PublishTransaction(w, req)
+
+	var er errorResponse
+	assert.Nil(t, json.NewDecoder(w.Result().Body).Decode(&er))
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	assert.Equal(t, codeTopicNotFound, er.Code)
+	assert.Equal(t, "topic was not found", er.Message)
+}
+
+// fakeTransactionalProducer delivers every message, failing the commit and abort with their errors.
+type fakeTransactionalProducer struct {
+	commitErr error
+	abortErr  error
+	commits   int
+	closed    bool
+}
+
+func (p *fakeTransactionalProducer) BeginTransaction() error {
+	return nil
+}
+
+func (p *fakeTransactionalProducer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	deliveryChan <- msg
+	return nil
+}
+
+func (p *fakeTransactionalProducer) CommitTransaction(ctx context.Context) error {
+	p.commits++
+	return p.commitErr
+}
+
+func (p *fakeTransactionalProducer) AbortTransaction(ctx context.Context) error {
+	return p.abortErr
+}
+
+func (p *fakeTransactionalProducer) Close() {
+	p.closed = true
+}
+
+func TestProduceTransactionDiscardsOpenTransactions(t *testing.T) {
+	setup()
+	Config.Transactions.PoolSize = 1
+	transactionalPools = newTransactionalPools()
+	defer func() {
+		Config.Transactions.PoolSize = 0
+		transactionalPools = nil
+	}()
+
+	tp, _ := lookupTransactionalPool("kafka-cl01")
+	slot := <-tp.slots
+
+	tests := []struct {
+		name      string
+		instance  *fakeTransactionalProducer
+		discarded bool
+	}{
+		{"committed", &fakeTransactionalProducer{}, false},
+		{"commit failed", &fakeTransactionalProducer{commitErr: kafka.NewError(kafka.ErrTimedOut, "timed out", false)}, true},
+		{"commit failed with other error", &fakeTransactionalProducer{commitErr: errors.New("commit failed")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot.instance = tt.instance
+			tp.release(slot)
+
+			p := producer{}
+			result := p.ProduceTransaction(TransactionOptions{
+				Context: context.Background(),
+				Cluster: "kafka-cl01",
+				Events:  []TransactionEvent{{Topic: "orders", Data: map[string]interface{}{"id": 1}}},
+			})
+
+			slot = <-tp.slots
+
+			assert.Equal(t, tt.discarded, result.Error != nil)
+			assert.Equal(t, 1, tt.instance.commits)
+			// the transaction may still be open, so the producer is closed instead of reused
+			assert.Equal(t, tt.discarded, tt.instance.closed)
+			assert.Equal(t, tt.discarded, slot.instance == nil)
+		})
+	}
+}
+
+func TestAbortTransaction(t *testing.T) {
+	_, completed, err := abortTransaction(&fakeTransactionalProducer{}, errors.New("delivery failed"))
+	assert.True(t, completed)
+	assert.EqualError(t, err, "transaction aborted: delivery failed")
+
+	_, completed, err = abortTransaction(&fakeTransactionalProducer{abortErr: errors.New("broker down")}, errors.New("delivery failed"))
+	assert.False(t, completed)
+	assert.NotNil(t, err)
+}
+
+func TestPrepareTransactionEvents(t *testing.T) {
+	kr, err := newKeyRules(map[string]keyRuleConfig{"orders": {Paths: []string{"$.id"}}})
+	assert.Nil(t, err)
+
+	messageKeyRules = kr
+	Config.Durability = durabilityConfig{
+		Enabled: true,
+		Topics: map[string][]string{
+			"telemetry": {durabilityNone},
+		},
+	}
+	defer func() {
+		messageKeyRules = keyRules{}
+		Config.Durability = durabilityConfig{}
+	}()
+
+	events, err := prepareTransactionEvents(TransactionOptions{
+		Context: context.Background(),
+		Cluster: "kafka-cl01",
+		Events: []TransactionEvent{
+			{Topic: "orders", Data: map[string]interface{}{"id": "o1"}},
+			{Topic: "payments", Key: "p1", Data: map[string]interface{}{}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "o1", events[0].Key)
+	assert.Equal(t, "p1", events[1].Key)
+
+	// the key can't be derived
+	_, err = prepareTransactionEvents(TransactionOptions{
+		Context: context.Background(),
+		Cluster: "kafka-cl01",
+		Events: []TransactionEvent{
+			{Topic: "payments", Key: "p1", Data: map[string]interface{}{}},
+			{Topic: "orders", Data: map[string]interface{}{}},
+		},
+	})
+	ae := classifyError(err)
+	assert.Equal(t, http.StatusBadRequest, ae.Status)
+	assert.Equal(t, "events[1].key", ae.Fields[0].Field)
+
+	// the topic doesn't allow acks=all
+	_, err = prepareTransactionEvents(TransactionOptions{
+		Context: context.Background(),
+		Cluster: "kafka-cl01",
+		Events:  []TransactionEvent{{Topic: "telemetry", Data: map[string]interface{}{}}},
+	})
+	assert.Equal(t, http.StatusForbidden, classifyError(err).Status)
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	transformAddField    = "addField"
+	transformRemoveField = "removeField"
+	transformRenameField = "renameField"
+	transformSetKey      = "setKey"
+
+	derivedProducedAt = "producedAt"
+	derivedPrincipal  = "principal"
+	derivedCluster    = "cluster"
+	derivedTopic      = "topic"
+)
+
+// payloadTransforms are the compiled transformation pipelines per topic.
+var payloadTransforms = transformPipelines{}
+
+// transformStepConfig configures a single step of a topic's pipeline. Paths are JSONPath
+// expressions into the event's data, e.g. `$.customer.id`.
+type transformStepConfig struct {
+	Type string `json:"type"`
+	// Path is the field added, removed or used as the key.
+	Path string `json:"path,omitempty"`
+	// From and To are the fields of a rename.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Value is the static value of an added field.
+	Value interface{} `json:"value,omitempty"`
+	// Derived names the value of an added field computed per message: producedAt, principal, cluster or topic.
+	Derived string `json:"derived,omitempty"`
+}
+
+// transformStep modifies the key and data of a message. The data is a copy owned by the pipeline.
+type transformStep interface {
+	apply(options *ProduceOptions)
+}
+
+type transformPipelines map[string][]transformStep
+
+// newTransformPipelines compiles the configured steps of every topic.
+func newTransformPipelines(config map[string][]transformStepConfig) (transformPipelines, error) {
+	pipelines := transformPipelines{}
+
+	for topic, steps := range config {
+		for i, sc := range steps {
+			step, err := newTransformStep(sc)
+			if err != nil {
+				return nil, fmt.Errorf("transform %d of topic '%s': %w", i, topic, err)
+			}
+
+			pipelines[topic] = append(pipelines[topic], step)
+		}
+	}
+
+	return pipelines, nil
+}
+
+func newTransformStep(sc transformStepConfig) (transformStep, error) {
+	switch sc.Type {
+	case transformAddField:
+		path, err := parseJSONPath(sc.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		switch sc.Derived {
+		case "", derivedProducedAt, derivedPrincipal, derivedCluster, derivedTopic:
+		default:
+			return nil, fmt.Errorf("unknown derived value '%s'", sc.Derived)
+		}
+
+		return addFieldStep{path: path, value: sc.Value, derived: sc.Derived}, nil
+	case transformRemoveField:
+		path, err := parseJSONPath(sc.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return removeFieldStep{path: path}, nil
+	case transformRenameField:
+		from, err := parseJSONPath(sc.From)
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := parseJSONPath(sc.To)
+		if err != nil {
+			return nil, err
+		}
+
+		return renameFieldStep{from: from, to: to}, nil
+	case transformSetKey:
+		path, err := parseJSONPath(sc.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return setKeyStep{path: path}, nil
+	}
+
+	return nil, fmt.Errorf("unknown transform type '%s'", sc.Type)
+}
+
+// Apply runs the topic's pipeline on a copy of the data, the caller's data is not modified.
+func (tp transformPipelines) Apply(options ProduceOptions) ProduceOptions {
+	steps := tp[options.Topic]
+	if len(steps) == 0 {
+		return options
+	}
+
+	if data, ok := deepCopy(options.Data).(map[string]interface{}); ok {
+		options.Data = data
+	} else {
+		options.Data = map[string]interface{}{}
+	}
+
+	for _, step := range steps {
+		step.apply(&options)
+	}
+
+	return options
+}
+
+type addFieldStep struct {
+	path    jsonPath
+	value   interface{}
+	derived string
+}
+
+func (s addFieldStep) apply(options *ProduceOptions) {
+	value := s.value
+
+	switch s.derived {
+	case derivedProducedAt:
+		value = time.Now().UTC().Format(time.RFC3339Nano)
+	case derivedPrincipal:
+		value = options.Principal
+	case derivedCluster:
+		value = options.Cluster
+	case derivedTopic:
+		value = options.Topic
+	}
+
+	if err := s.path.Set(options.Data, value); err != nil && options.Log != nil {
+		options.Log.Warn().Err(err).Msg("transform addField skipped")
+	}
+}
+
+type removeFieldStep struct {
+	path jsonPath
+}
+
+func (s removeFieldStep) apply(options *ProduceOptions) {
+	s.path.Delete(options.Data)
+}
+
+type renameFieldStep struct {
+	from jsonPath
+	to   jsonPath
+}
+
+func (s renameFieldStep) apply(options *ProduceOptions) {
+	values := s.from.Get(options.Data)
+	if len(values) == 0 {
+		return
+	}
+
+	s.from.Delete(options.Data)
+
+	if err := s.to.Set(options.Data, values[0]); err != nil && options.Log != nil {
+		options.Log.Warn().Err(err).Msg("transform renameField skipped")
+	}
+}
+
+// setKeyStep replaces the key with the first value matching the path, the key is left
+// unchanged when nothing matches.
+type setKeyStep struct {
+	path jsonPath
+}
+
+func (s setKeyStep) apply(options *ProduceOptions) {
+	values := s.path.Get(options.Data)
+	if len(values) == 0 || values[0] == nil {
+		return
+	}
+
+	options.Key = values[0]
+}
+
+// deepCopy copies the maps and slices of a decoded JSON document.
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			c[key] = deepCopy(value)
+		}
+
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, value := range v {
+			c[i] = deepCopy(value)
+		}
+
+		return c
+	}
+
+	return v
+}
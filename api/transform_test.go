@@ -0,0 +1,120 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformPipelines(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []transformStepConfig
+		key   interface{}
+		data  map[string]interface{}
+		want  map[string]interface{}
+		key2  interface{}
+	}{
+		{
+			name:  "add static field",
+			steps: []transformStepConfig{{Type: "addField", Path: "$.meta.source", Value: "proxy"}},
+			data:  map[string]interface{}{"id": "1"},
+			want:  map[string]interface{}{"id": "1", "meta": map[string]interface{}{"source": "proxy"}},
+		},
+		{
+			name:  "add derived fields",
+			steps: []transformStepConfig{{Type: "addField", Path: "$.by", Derived: "principal"}, {Type: "addField", Path: "$.topic", Derived: "topic"}},
+			data:  map[string]interface{}{},
+			want:  map[string]interface{}{"by": "token:abc", "topic": "orders"},
+		},
+		{
+			name:  "remove field",
+			steps: []transformStepConfig{{Type: "removeField", Path: "$.items[*].internal"}},
+			data:  map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": "1", "internal": true}}},
+			want:  map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": "1"}}},
+		},
+		{
+			name:  "rename field",
+			steps: []transformStepConfig{{Type: "renameField", From: "$.customerId", To: "$.customer.id"}},
+			data:  map[string]interface{}{"customerId": "c1"},
+			want:  map[string]interface{}{"customer": map[string]interface{}{"id": "c1"}},
+		},
+		{
+			name:  "rename missing field",
+			steps: []transformStepConfig{{Type: "renameField", From: "$.customerId", To: "$.customer.id"}},
+			data:  map[string]interface{}{"id": "1"},
+			want:  map[string]interface{}{"id": "1"},
+		},
+		{
+			name:  "set key",
+			steps: []transformStepConfig{{Type: "setKey", Path: "$.customer.id"}},
+			key:   "old",
+			data:  map[string]interface{}{"customer": map[string]interface{}{"id": "c1"}},
+			want:  map[string]interface{}{"customer": map[string]interface{}{"id": "c1"}},
+			key2:  "c1",
+		},
+		{
+			name:  "set key from missing field",
+			steps: []transformStepConfig{{Type: "setKey", Path: "$.customer.id"}},
+			key:   "old",
+			data:  map[string]interface{}{},
+			want:  map[string]interface{}{},
+			key2:  "old",
+		},
+		{
+			name:  "steps run in order",
+			steps: []transformStepConfig{{Type: "renameField", From: "$.a", To: "$.b"}, {Type: "setKey", Path: "$.b"}, {Type: "removeField", Path: "$.b"}},
+			data:  map[string]interface{}{"a": "k"},
+			want:  map[string]interface{}{},
+			key2:  "k",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, err := newTransformPipelines(map[string][]transformStepConfig{"orders": tt.steps})
+			assert.Nil(t, err)
+
+			options := tp.Apply(ProduceOptions{Topic: "orders", Principal: "token:abc", Key: tt.key, Data: tt.data})
+
+			assert.Equal(t, tt.want, options.Data)
+			assert.Equal(t, tt.key2, options.Key)
+		})
+	}
+}
+
+func TestTransformPipelinesLeavesInputUntouched(t *testing.T) {
+	tp, err := newTransformPipelines(map[string][]transformStepConfig{
+		"orders": {{Type: "removeField", Path: "$.customer.ssn"}, {Type: "addField", Path: "$.producedAt", Derived: "producedAt"}},
+	})
+	assert.Nil(t, err)
+
+	data := map[string]interface{}{"customer": map[string]interface{}{"ssn": "123"}}
+	options := tp.Apply(ProduceOptions{Topic: "orders", Data: data})
+
+	assert.Equal(t, "123", data["customer"].(map[string]interface{})["ssn"])
+	assert.NotContains(t, options.Data["customer"], "ssn")
+
+	_, err = time.Parse(time.RFC3339Nano, options.Data["producedAt"].(string))
+	assert.Nil(t, err)
+
+	// other topics are not transformed
+	other := tp.Apply(ProduceOptions{Topic: "payments", Data: data})
+	assert.Equal(t, data, other.Data)
+}
+
+func TestNewTransformPipelinesErrors(t *testing.T) {
+	tests := []transformStepConfig{
+		{Type: "upperCase", Path: "$.a"},
+		{Type: "addField", Path: "a"},
+		{Type: "addField", Path: "$.a", Derived: "hostname"},
+		{Type: "renameField", From: "$.a", To: ""},
+		{Type: "setKey", Path: "$.a["},
+	}
+
+	for _, sc := range tests {
+		_, err := newTransformPipelines(map[string][]transformStepConfig{"orders": {sc}})
+		assert.NotNil(t, err, sc)
+	}
+}
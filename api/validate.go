@@ -0,0 +1,335 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	secretsFile = "secrets.json"
+	certFile    = "internal-ca.json"
+)
+
+var (
+	securityProtocols = map[string]bool{"plaintext": true, "ssl": true, "sasl_plaintext": true, "sasl_ssl": true}
+	saslMechanisms    = map[string]bool{"PLAIN": true, "SCRAM-SHA-256": true, "SCRAM-SHA-512": true, "GSSAPI": true, "OAUTHBEARER": true}
+	// saslCredentialMechanisms authenticate with sasl.username and sasl.password.
+	saslCredentialMechanisms = map[string]bool{"PLAIN": true, "SCRAM-SHA-256": true, "SCRAM-SHA-512": true}
+)
+
+// ValidateConfig loads the app-config.json, the secrets.json and, with useKafkaCertAuth, the
+// internal-ca.json of the secrets path without applying them, returning every problem found
+// instead of the first one the proxy would fail on when starting.
+func ValidateConfig(appConfigFile, secretsPath string) []error {
+	var c appConfig
+
+	if err := decodeStrict(appConfigFile, &c); err != nil {
+		return []error{err}
+	}
+
+	errs := checkAppConfig(&c)
+	errs = append(errs, checkServerTLS(&c)...)
+
+	// decoded leniently like SetAppSecrets, since the proxy ignores unknown fields of the secrets
+	var s appSecrets
+
+	if b, err := ioutil.ReadFile(filepath.Join(secretsPath, secretsFile)); err != nil {
+		errs = append(errs, err)
+	} else if err := json.Unmarshal(b, &s); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", secretsFile, err))
+	} else {
+		errs = append(errs, checkSecrets(&c, &s)...)
+	}
+
+	if c.UseKafkaCertAuth {
+		errs = append(errs, checkCertConfig(filepath.Join(secretsPath, certFile))...)
+	}
+
+	return errs
+}
+
+// checkAppConfig returns the problems of the app-config.json that don't need the secrets.
+func checkAppConfig(c *appConfig) []error {
+	errs := []error{}
+
+	if _, err := newRedactor(c.LogRedaction); err != nil {
+		errs = append(errs, fmt.Errorf("logRedaction: %w", err))
+	}
+
+	if _, err := newTransformPipelines(c.Transforms); err != nil {
+		errs = append(errs, fmt.Errorf("transforms: %w", err))
+	}
+
+	if _, err := newKeyRules(c.KeyRules); err != nil {
+		errs = append(errs, fmt.Errorf("keyRules: %w", err))
+	}
+
+	if _, err := newTopicPolicy(c.TopicAdmin); err != nil {
+		errs = append(errs, fmt.Errorf("topicAdmin: %w", err))
+	}
+
+	if err := validateProducerConfig(c); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateDurabilityConfig(c.Durability); err != nil {
+		errs = append(errs, err)
+	}
+
+	if !validPort(c.ServerPort) {
+		errs = append(errs, fmt.Errorf("serverPort %d must be between 1 and 65535", c.ServerPort))
+	}
+
+	if c.GRPCPort != 0 && !validPort(c.GRPCPort) {
+		errs = append(errs, fmt.Errorf("grpcPort %d must be between 1 and 65535", c.GRPCPort))
+	}
+
+	if c.GRPCPort != 0 && c.GRPCPort == c.ServerPort {
+		errs = append(errs, fmt.Errorf("grpcPort %d is the serverPort", c.GRPCPort))
+	}
+
+	if len(c.KafkaBrokerGroups) == 0 {
+		errs = append(errs, fmt.Errorf("kafkaBrokerGroups is empty"))
+	}
+
+	for primary, fallbacks := range c.KafkaFailover {
+		if !contains(c.KafkaBrokerGroups, primary) {
+			errs = append(errs, fmt.Errorf("kafkaFailover cluster '%s' is not in kafkaBrokerGroups", primary))
+		}
+
+		for _, fallback := range fallbacks {
+			if !contains(c.KafkaBrokerGroups, fallback) {
+				errs = append(errs, fmt.Errorf("kafkaFailover[%s] fallback '%s' is not in kafkaBrokerGroups", primary, fallback))
+			}
+		}
+	}
+
+	errs = append(errs, checkRateLimits(c)...)
+
+	return errs
+}
+
+// checkRateLimits returns the problems of the rateLimits, also checked when they are reloaded.
+func checkRateLimits(c *appConfig) []error {
+	errs := []error{}
+
+	for i, rule := range c.RateLimits {
+		if err := rule.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rateLimits[%d]: %w", i, err))
+		}
+
+		if len(rule.Cluster) > 0 && rule.Cluster != rateLimitWildcard && !contains(c.KafkaBrokerGroups, rule.Cluster) {
+			errs = append(errs, fmt.Errorf("rateLimits[%d] cluster '%s' is not in kafkaBrokerGroups", i, rule.Cluster))
+		}
+	}
+
+	return errs
+}
+
+// checkServerTLS ensures the certificate and key of enableTLS can be loaded.
+func checkServerTLS(c *appConfig) []error {
+	if !c.EnableTLS {
+		return nil
+	}
+
+	if len(c.TLSCert) == 0 || len(c.TLSKey) == 0 {
+		return []error{fmt.Errorf("enableTLS requires tlsCert and tlsKey")}
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+	if err != nil {
+		return []error{fmt.Errorf("tlsCert and tlsKey: %w", err)}
+	}
+
+	if err := checkExpiry(cert.Certificate[0]); err != nil {
+		return []error{fmt.Errorf("tlsCert: %w", err)}
+	}
+
+	return nil
+}
+
+// checkSecrets cross-references the clusters of the app-config.json with the kafkaSecrets and
+// checks their security settings.
+func checkSecrets(c *appConfig, s *appSecrets) []error {
+	errs := []error{}
+
+	if c.EnableAPIAuth && len(s.APIToken) == 0 {
+		errs = append(errs, fmt.Errorf("enableApiAuth requires the apiToken secret"))
+	}
+
+	for _, cluster := range c.KafkaBrokerGroups {
+		kc, ok := s.KafkaSecrets[cluster]
+		if !ok {
+			errs = append(errs, fmt.Errorf("kafkaBrokerGroups cluster '%s' is not in kafkaSecrets", cluster))
+			continue
+		}
+
+		for _, err := range checkKafkaConfig(kc, c.UseKafkaCertAuth) {
+			errs = append(errs, fmt.Errorf("kafkaSecrets[%s]: %w", cluster, err))
+		}
+	}
+
+	return errs
+}
+
+// checkKafkaConfig checks the brokers and the combination of security settings, clientConfigMap
+// only applies the sasl settings without useKafkaCertAuth.
+func checkKafkaConfig(kc KafkaConfig, certAuth bool) []error {
+	errs := []error{}
+
+	if len(strings.TrimSpace(kc.BootstrapServers)) == 0 {
+		errs = append(errs, fmt.Errorf("bootstrap.servers is required"))
+	}
+
+	for _, server := range strings.Split(kc.BootstrapServers, ",") {
+		if server = strings.TrimSpace(server); len(server) == 0 {
+			continue
+		}
+
+		_, port, err := net.SplitHostPort(server)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bootstrap.servers '%s' must be host:port", server))
+			continue
+		}
+
+		if p, err := strconv.Atoi(port); err != nil || !validPort(p) {
+			errs = append(errs, fmt.Errorf("bootstrap.servers '%s' has an invalid port", server))
+		}
+	}
+
+	protocol := strings.ToLower(kc.SecurityProtocol)
+	if len(protocol) == 0 {
+		protocol = "plaintext"
+	}
+
+	if !securityProtocols[protocol] {
+		errs = append(errs, fmt.Errorf("security.protocol '%s' is not supported", kc.SecurityProtocol))
+		return errs
+	}
+
+	sasl := strings.HasPrefix(protocol, "sasl_")
+
+	if certAuth {
+		if protocol != "ssl" {
+			errs = append(errs, fmt.Errorf("useKafkaCertAuth requires security.protocol ssl, not '%s'", protocol))
+		}
+
+		return errs
+	}
+
+	location := os.Getenv("KAFKA_PRODUCER_PROXY_SSL_CA_LOCATION")
+	if strings.HasSuffix(protocol, "ssl") && len(location) > 0 {
+		if _, err := os.Stat(location); err != nil {
+			errs = append(errs, fmt.Errorf("KAFKA_PRODUCER_PROXY_SSL_CA_LOCATION: %w", err))
+		}
+	}
+
+	if !sasl {
+		if len(kc.SaslMechanisms) > 0 {
+			errs = append(errs, fmt.Errorf("sasl.mechanisms requires security.protocol sasl_plaintext or sasl_ssl, not '%s'", protocol))
+		}
+
+		return errs
+	}
+
+	mechanism := strings.ToUpper(kc.SaslMechanisms)
+
+	switch {
+	case len(mechanism) == 0:
+		errs = append(errs, fmt.Errorf("security.protocol '%s' requires sasl.mechanisms", protocol))
+	case !saslMechanisms[mechanism]:
+		errs = append(errs, fmt.Errorf("sasl.mechanisms '%s' is not supported", kc.SaslMechanisms))
+	case saslCredentialMechanisms[mechanism] && (len(kc.Username) == 0 || len(kc.Password) == 0):
+		errs = append(errs, fmt.Errorf("sasl.mechanisms '%s' requires sasl.username and sasl.password", mechanism))
+	}
+
+	return errs
+}
+
+// checkCertConfig ensures the internal-ca.json has a CA chain and a certificate matching its key.
+func checkCertConfig(file string) []error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return []error{err}
+	}
+
+	// issued by Vault's PKI, which returns more fields than the proxy uses
+	var cfg certConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return []error{fmt.Errorf("%s: %w", certFile, err)}
+	}
+
+	errs := []error{}
+
+	if len(cfg.CAChain) == 0 {
+		errs = append(errs, fmt.Errorf("%s: ca_chain is empty", certFile))
+	}
+
+	for i, ca := range cfg.CAChain {
+		block, _ := pem.Decode([]byte(ca))
+		if block == nil {
+			errs = append(errs, fmt.Errorf("%s: ca_chain[%d] is not a PEM certificate", certFile, i))
+			continue
+		}
+
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			errs = append(errs, fmt.Errorf("%s: ca_chain[%d]: %w", certFile, i, err))
+		}
+	}
+
+	cert, err := tls.X509KeyPair([]byte(cfg.Certificate), []byte(cfg.PrivateKey))
+	if err != nil {
+		return append(errs, fmt.Errorf("%s: certificate and private_key: %w", certFile, err))
+	}
+
+	if err := checkExpiry(cert.Certificate[0]); err != nil {
+		errs = append(errs, fmt.Errorf("%s: certificate: %w", certFile, err))
+	}
+
+	return errs
+}
+
+func checkExpiry(der []byte) error {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+
+	if time.Now().After(cert.NotAfter) {
+		return fmt.Errorf("expired on %s", cert.NotAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// decodeStrict decodes the JSON file into v, rejecting unknown fields.
+func decodeStrict(file string, v interface{}) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(file), err)
+	}
+
+	return nil
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
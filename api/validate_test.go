@@ -0,0 +1,140 @@
+package api
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	errs := ValidateConfig("../testdata/app-config.json", "../testdata")
+
+	// the certificate of the testdata expired
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), "internal-ca.json: certificate: expired")
+	}
+
+	assert.Len(t, ValidateConfig("missing.json", "../testdata"), 1)
+}
+
+func TestValidateConfigCrossReferences(t *testing.T) {
+	dir := t.TempDir()
+
+	appConfig := filepath.Join(dir, "app-config.json")
+	assert.Nil(t, ioutil.WriteFile(appConfig, []byte(`{
+		"serverPort": 39000,
+		"grpcPort": 39000,
+		"enableApiAuth": true,
+		"enableTLS": true,
+		"kafkaBrokerGroups": ["kafka-cl01", "kafka-cl02"],
+		"kafkaFailover": {"kafka-cl01": ["kafka-cl03"]}
+	}`), 0600))
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "secrets.json"), []byte(`{
+		"kafkaSecrets": {
+			"kafka-cl01": {"bootstrap.servers": "broker01:9092", "security.protocol": "sasl_ssl", "sasl.mechanisms": "PLAIN"}
+		}
+	}`), 0600))
+
+	problems := []string{}
+	for _, err := range ValidateConfig(appConfig, dir) {
+		problems = append(problems, err.Error())
+	}
+
+	assert.Equal(t, []string{
+		"grpcPort 39000 is the serverPort",
+		"kafkaFailover[kafka-cl01] fallback 'kafka-cl03' is not in kafkaBrokerGroups",
+		"enableTLS requires tlsCert and tlsKey",
+		"enableApiAuth requires the apiToken secret",
+		"kafkaSecrets[kafka-cl01]: sasl.mechanisms 'PLAIN' requires sasl.username and sasl.password",
+		"kafkaBrokerGroups cluster 'kafka-cl02' is not in kafkaSecrets",
+	}, problems)
+}
+
+func TestValidateConfigLenientSecrets(t *testing.T) {
+	dir := t.TempDir()
+
+	appConfig := filepath.Join(dir, "app-config.json")
+	assert.Nil(t, ioutil.WriteFile(appConfig, []byte(`{"serverPort":39000,"kafkaBrokerGroups":["kafka-cl01"]}`), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "secrets.json"), []byte(`{"kafkaSecret":{}}`), 0600))
+
+	// unknown fields are ignored like the proxy does, the misspelled kafkaSecrets is still caught
+	errs := ValidateConfig(appConfig, dir)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "kafkaBrokerGroups cluster 'kafka-cl01' is not in kafkaSecrets", errs[0].Error())
+	}
+}
+
+func TestCheckKafkaConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		kc       KafkaConfig
+		certAuth bool
+		problems []string
+	}{
+		{
+			name: "plaintext",
+			kc:   KafkaConfig{BootstrapServers: "broker01:9092, broker02:9092"},
+		},
+		{
+			name: "scram",
+			kc:   KafkaConfig{BootstrapServers: "broker01:9094", SecurityProtocol: "SASL_SSL", SaslMechanisms: "SCRAM-SHA-512", Username: "proxy", Password: "secret"},
+		},
+		{
+			name:     "certificate",
+			kc:       KafkaConfig{BootstrapServers: "broker01:9093", SecurityProtocol: "ssl"},
+			certAuth: true,
+		},
+		{
+			name:     "missing servers",
+			kc:       KafkaConfig{SecurityProtocol: "ssl"},
+			problems: []string{"bootstrap.servers is required"},
+		},
+		{
+			name:     "invalid servers",
+			kc:       KafkaConfig{BootstrapServers: "broker01,broker02:99999"},
+			problems: []string{"must be host:port", "has an invalid port"},
+		},
+		{
+			name:     "unknown protocol",
+			kc:       KafkaConfig{BootstrapServers: "broker01:9092", SecurityProtocol: "tls"},
+			problems: []string{"security.protocol 'tls' is not supported"},
+		},
+		{
+			name:     "certificate with sasl",
+			kc:       KafkaConfig{BootstrapServers: "broker01:9092", SecurityProtocol: "sasl_ssl"},
+			certAuth: true,
+			problems: []string{"useKafkaCertAuth requires security.protocol ssl"},
+		},
+		{
+			name:     "sasl without protocol",
+			kc:       KafkaConfig{BootstrapServers: "broker01:9092", SaslMechanisms: "PLAIN"},
+			problems: []string{"sasl.mechanisms requires security.protocol"},
+		},
+		{
+			name:     "sasl without mechanism",
+			kc:       KafkaConfig{BootstrapServers: "broker01:9092", SecurityProtocol: "sasl_plaintext"},
+			problems: []string{"requires sasl.mechanisms"},
+		},
+		{
+			name:     "unknown mechanism",
+			kc:       KafkaConfig{BootstrapServers: "broker01:9092", SecurityProtocol: "sasl_plaintext", SaslMechanisms: "SCRAM"},
+			problems: []string{"sasl.mechanisms 'SCRAM' is not supported"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := checkKafkaConfig(tt.kc, tt.certAuth)
+
+			if assert.Len(t, errs, len(tt.problems)) {
+				for i, problem := range tt.problems {
+					assert.True(t, strings.Contains(errs[i].Error(), problem), errs[i].Error())
+				}
+			}
+		})
+	}
+}
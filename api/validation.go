@@ -0,0 +1,232 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	defaultMaxBodyBytes = 1 << 20
+	// librdkafka's default message.max.bytes
+	defaultMessageMaxBytes = 1000000
+	maxTopicNameLength     = 249
+
+	fieldCodeRequired          = "required"
+	fieldCodeInvalidCharacters = "invalid_characters"
+	fieldCodeTooLong           = "too_long"
+	fieldCodeOutOfRange        = "out_of_range"
+	fieldCodeInvalid           = "invalid"
+)
+
+var legalTopicName = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// fieldError describes why a field of the request is invalid.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// maxBodyBytes returns the maximum size of a request body.
+func maxBodyBytes() int64 {
+	if Config.MaxBodyBytes <= 0 {
+		return defaultMaxBodyBytes
+	}
+
+	return Config.MaxBodyBytes
+}
+
+// decodeBody strictly decodes the JSON body into v, rejecting unknown fields and bodies
+// larger than maxBodyBytes.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) *apiError {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes())
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	if dec.More() {
+		return &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    codeInvalidJSON,
+			Message: "request body must contain a single JSON object",
+		}
+	}
+
+	return nil
+}
+
+func decodeError(err error) *apiError {
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	var mbe *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &mbe):
+		return &apiError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    codeBodyTooLarge,
+			Message: fmt.Sprintf("request body must not be larger than %d bytes", maxBodyBytes()),
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    codeUnknownField,
+			Message: fmt.Sprintf("request body contains unknown field '%s'", field),
+			Fields:  []fieldError{{Field: field, Code: codeUnknownField, Message: "unknown field"}},
+		}
+	case errors.As(err, &te):
+		return &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    codeValidationFailed,
+			Message: fmt.Sprintf("field '%s' must be of type %s", te.Field, te.Type.String()),
+			Fields:  []fieldError{{Field: te.Field, Code: "invalid_type", Message: fmt.Sprintf("must be of type %s", te.Type.String())}},
+		}
+	case errors.As(err, &se), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    codeInvalidJSON,
+			Message: "request body is not valid JSON",
+			Err:     err,
+		}
+	}
+
+	return &apiError{
+		Status:  http.StatusBadRequest,
+		Code:    codeInvalidJSON,
+		Message: "error deserializing request body",
+		Err:     err,
+	}
+}
+
+// validateTopic returns the problems with the topic name, following Kafka's naming rules.
+func validateTopic(field, topic string) []fieldError {
+	switch {
+	case len(topic) == 0:
+		return []fieldError{{field, fieldCodeRequired, "topic is required"}}
+	case len(topic) > maxTopicNameLength:
+		return []fieldError{{field, fieldCodeTooLong, fmt.Sprintf("topic must not be longer than %d characters", maxTopicNameLength)}}
+	case topic == "." || topic == ".." || !legalTopicName.MatchString(topic):
+		return []fieldError{{field, fieldCodeInvalidCharacters, "topic may only contain ASCII alphanumerics, '.', '_' and '-'"}}
+	}
+
+	return nil
+}
+
+// validateEventRequest checks the required fields and the size of the event.
+func validateEventRequest(er EventRequest) *apiError {
+	if re := validateEventFields(er); re != nil {
+		return re
+	}
+
+	return checkMessageSize(er.Cluster, er.Key, er.Data)
+}
+
+// validateEventFields checks the required fields of the event.
+func validateEventFields(er EventRequest) *apiError {
+	fields := []fieldError{}
+
+	if len(er.Cluster) == 0 {
+		fields = append(fields, fieldError{"cluster", fieldCodeRequired, "cluster is required"})
+	}
+
+	fields = append(fields, validateTopic("topic", er.Topic)...)
+
+	if len(fields) > 0 {
+		return validationError(fields)
+	}
+
+	return nil
+}
+
+// validateTransactionRequest checks the required fields of the transaction and each event.
+func validateTransactionRequest(tr TransactionRequest) *apiError {
+	fields := []fieldError{}
+
+	if len(tr.Cluster) == 0 {
+		fields = append(fields, fieldError{"cluster", fieldCodeRequired, "cluster is required"})
+	}
+
+	if len(tr.Events) == 0 {
+		fields = append(fields, fieldError{"events", fieldCodeRequired, "a transaction requires at least one event"})
+	}
+
+	for i, e := range tr.Events {
+		fields = append(fields, validateTopic(fmt.Sprintf("events[%d].topic", i), e.Topic)...)
+	}
+
+	if len(fields) > 0 {
+		return validationError(fields)
+	}
+
+	for _, e := range tr.Events {
+		if re := checkMessageSize(tr.Cluster, e.Key, e.Data); re != nil {
+			return re
+		}
+	}
+
+	return nil
+}
+
+func validationError(fields []fieldError) *apiError {
+	return &apiError{
+		Status:  http.StatusBadRequest,
+		Code:    codeValidationFailed,
+		Message: "request is invalid",
+		Fields:  fields,
+	}
+}
+
+// checkMessageSize rejects messages larger than the cluster's message.max.bytes.
+func checkMessageSize(cluster string, key interface{}, data map[string]interface{}) *apiError {
+	msg, result := newMessage("", key, data)
+	if result != nil {
+		return &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    codeValidationFailed,
+			Message: result.Message,
+			Err:     result.Error,
+		}
+	}
+
+	return checkMessageBytes(cluster, msg)
+}
+
+// checkMessageBytes rejects a serialized message larger than the cluster's message.max.bytes.
+func checkMessageBytes(cluster string, msg *kafka.Message) *apiError {
+	max := messageMaxBytes(cluster)
+	if size := len(msg.Key) + len(msg.Value); size > max {
+		return &apiError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    codeMessageTooLarge,
+			Message: fmt.Sprintf("message of %d bytes is larger than the %d bytes allowed by the cluster", size, max),
+		}
+	}
+
+	return nil
+}
+
+// messageMaxBytes returns the message.max.bytes of the cluster from producerConfig.
+func messageMaxBytes(cluster string) int {
+	kcm := kafka.ConfigMap{}
+	if err := applyProducerConfig(cluster, kcm); err != nil {
+		return defaultMessageMaxBytes
+	}
+
+	if v, ok := kcm["message.max.bytes"].(int); ok && v > 0 {
+		return v
+	}
+
+	return defaultMessageMaxBytes
+}
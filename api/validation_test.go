@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishEventValidation(t *testing.T) {
+	setup()
+	Config.MaxBodyBytes = 256
+	Config.ProducerConfig = map[string]map[string]interface{}{
+		"kafka-cl01": {"message.max.bytes": float64(64)},
+	}
+	defer func() {
+		Config.MaxBodyBytes = 0
+		Config.ProducerConfig = nil
+	}()
+
+	rh := router{kp: &countingProducer{}}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{"valid", `{"cluster": "kafka-cl01", "topic": "orders.v1", "data": {"id": 1}}`, http.StatusOK, "", ""},
+		{"unknown field", `{"cluster": "kafka-cl01", "topc": "orders", "data": {}}`, http.StatusBadRequest, codeUnknownField, "topc"},
+		{"invalid json", `{"cluster": "kafka-cl01",`, http.StatusBadRequest, codeInvalidJSON, ""},
+		{"missing cluster", `{"topic": "orders", "data": {}}`, http.StatusBadRequest, codeValidationFailed, "cluster"},
+		{"empty topic", `{"cluster": "kafka-cl01", "topic": "", "data": {}}`, http.StatusBadRequest, codeValidationFailed, "topic"},
+		{"invalid topic", `{"cluster": "kafka-cl01", "topic": "orders/v1", "data": {}}`, http.StatusBadRequest, codeValidationFailed, "topic"},
+		{"body too large", `{"cluster": "kafka-cl01", "topic": "orders", "data": {"value": "` + strings.Repeat("a", 256) + `"}}`, http.StatusRequestEntityTooLarge, codeBodyTooLarge, ""},
+		{"message too large", `{"cluster": "kafka-cl01", "topic": "orders", "data": {"value": "` + strings.Repeat("a", 64) + `"}}`, http.StatusRequestEntityTooLarge, codeMessageTooLarge, ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/events", strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		rh.PublishEvent(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, test.status, resp.StatusCode, test.name)
+
+		if test.status == http.StatusOK {
+			continue
+		}
+
+		var er errorResponse
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&er), test.name)
+		assert.Equal(t, test.code, er.Code, test.name)
+
+		if len(test.field) > 0 && assert.Len(t, er.Fields, 1, test.name) {
+			assert.Equal(t, test.field, er.Fields[0].Field, test.name)
+		}
+	}
+}
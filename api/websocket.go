@@ -0,0 +1,267 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+)
+
+const (
+	defaultWebSocketMaxInFlight = 64
+
+	wsFrameAuth  = "auth"
+	wsFrameEvent = "event"
+	wsFrameAck   = "ack"
+	wsFrameError = "error"
+
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+type webSocketConfig struct {
+	// MaxInFlight is the number of unacknowledged events per connection, further frames aren't
+	// read until an event is acknowledged. Defaults to 64.
+	MaxInFlight int `json:"maxInFlight,omitempty"`
+	// AllowedOrigins are the origins allowed besides the proxy's own, `*` allows every origin.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+}
+
+func webSocketMaxInFlight() int {
+	if Config.WebSocket.MaxInFlight <= 0 {
+		return defaultWebSocketMaxInFlight
+	}
+
+	return Config.WebSocket.MaxInFlight
+}
+
+// wsRequest is a frame sent by the client, an event (the default) or the API token.
+type wsRequest struct {
+	Type string `json:"type,omitempty"`
+	// ID correlates the ack or error frame with the event, chosen by the client.
+	ID       string `json:"id,omitempty"`
+	APIToken string `json:"apiToken,omitempty"`
+	EventRequest
+}
+
+// wsReply is an ack or error frame sent to the client.
+type wsReply struct {
+	Type      string       `json:"type"`
+	ID        string       `json:"id,omitempty"`
+	Message   string       `json:"message,omitempty"`
+	Cluster   string       `json:"cluster,omitempty"`
+	Spooled   bool         `json:"spooled,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Retriable bool         `json:"retriable,omitempty"`
+	Fields    []fieldError `json:"fields,omitempty"`
+}
+
+func newWSError(id string, err error) wsReply {
+	ae := classifyError(err)
+
+	return wsReply{
+		Type:      wsFrameError,
+		ID:        id,
+		Message:   ae.Message,
+		Code:      ae.Code,
+		Retriable: ae.Retriable,
+		Fields:    ae.Fields,
+	}
+}
+
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: checkWebSocketOrigin,
+}
+
+// checkWebSocketOrigin allows requests without an origin, from the proxy's own host and the allowed origins.
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, allowed := range Config.WebSocket.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PublishWebSocket upgrades to a WebSocket on which the client sends event frames and receives an
+// ack or error frame per event once its delivery report arrives. Clients that can't send the
+// X-API-TOKEN header authenticate with an auth frame first.
+func (rh router) PublishWebSocket(w http.ResponseWriter, r *http.Request) {
+	log := hlog.FromRequest(r)
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already wrote the error response
+		log.Warn().Err(err).Msg("websocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	ws := &wsSession{
+		rh:         rh,
+		conn:       conn,
+		log:        log,
+		ctx:        r.Context(),
+		principal:  principal(r),
+		remoteAddr: r.RemoteAddr,
+		replies:    make(chan wsReply),
+		inFlight:   make(chan struct{}, webSocketMaxInFlight()),
+	}
+
+	ws.authenticated = checkAPIToken(r.Header.Get("X-API-TOKEN"), r.RemoteAddr) == nil
+
+	ws.Run()
+}
+
+type wsSession struct {
+	rh            router
+	conn          *websocket.Conn
+	log           *zerolog.Logger
+	ctx           context.Context
+	principal     string
+	remoteAddr    string
+	authenticated bool
+	replies       chan wsReply
+	inFlight      chan struct{}
+}
+
+// Run reads frames until the connection is closed, then waits on the events in flight.
+func (ws *wsSession) Run() {
+	done := make(chan struct{})
+	go ws.write(done)
+
+	ws.conn.SetReadLimit(maxBodyBytes())
+	ws.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	ws.conn.SetPongHandler(func(string) error {
+		return ws.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	ws.read()
+
+	// wait on the acks of the events in flight before closing the writer
+	for i := 0; i < cap(ws.inFlight); i++ {
+		ws.inFlight <- struct{}{}
+	}
+
+	close(ws.replies)
+	<-done
+}
+
+func (ws *wsSession) read() {
+	for {
+		_, b, err := ws.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				ws.log.Warn().Err(err).Msg("websocket closed")
+			}
+			return
+		}
+
+		var req wsRequest
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(&req); err != nil {
+			ws.replies <- newWSError("", decodeError(err))
+			continue
+		}
+
+		switch req.Type {
+		case wsFrameAuth:
+			if err := checkAPIToken(req.APIToken, ws.remoteAddr); err != nil {
+				ws.replies <- newWSError(req.ID, err)
+				return
+			}
+
+			ws.authenticated = true
+			ws.principal = principalOf(req.APIToken, ws.remoteAddr)
+			ws.replies <- wsReply{Type: wsFrameAck, ID: req.ID}
+		case "", wsFrameEvent:
+			if !ws.authenticated {
+				ws.replies <- newWSError(req.ID, checkAPIToken("", ws.remoteAddr))
+				return
+			}
+
+			if re := validateEventRequest(req.EventRequest); re != nil {
+				ws.replies <- newWSError(req.ID, re)
+				continue
+			}
+
+			// flow control, stop reading while the connection has too many events in flight
+			ws.inFlight <- struct{}{}
+			go ws.publish(req)
+		default:
+			ws.replies <- newWSError(req.ID, validationError([]fieldError{{"type", "unsupported", "type must be auth or event"}}))
+		}
+	}
+}
+
+func (ws *wsSession) publish(req wsRequest) {
+	defer func() { <-ws.inFlight }()
+
+	result, err := ws.rh.produceEvent(ws.ctx, ws.log, ws.principal, req.EventRequest)
+	if err != nil {
+		ws.replies <- newWSError(req.ID, err)
+		return
+	}
+
+	ws.replies <- wsReply{Type: wsFrameAck, ID: req.ID, Message: result.Message, Cluster: result.Cluster, Spooled: result.Spooled}
+}
+
+// write is the only writer of the connection, sending replies and pings.
+func (ws *wsSession) write(done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case reply, ok := <-ws.replies:
+			ws.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+
+			if !ok {
+				ws.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+
+			if err := ws.conn.WriteJSON(reply); err != nil {
+				ws.log.Warn().Err(err).Msg("error writing websocket reply")
+				// keep draining so publishers don't block
+				for range ws.replies {
+				}
+				return
+			}
+		case <-ticker.C:
+			ws.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+
+			if err := ws.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				ws.log.Debug().Err(err).Msg("error writing websocket ping")
+			}
+		}
+	}
+}
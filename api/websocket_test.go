@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func dialWebSocket(t *testing.T, kp kafkaProducer, header http.Header) *websocket.Conn {
+	mr := mux.NewRouter()
+	mr.Use(hlog.NewHandler(zerolog.Nop()))
+	mr.Use(hlog.AccessHandler(func(*http.Request, int, int, time.Duration) {}))
+	configureRouter(mr, kp)
+
+	srv := httptest.NewServer(mr)
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/events/ws", header)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestPublishWebSocket(t *testing.T) {
+	setup()
+
+	cp := &countingProducer{}
+	conn := dialWebSocket(t, cp, nil)
+
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id": "1", "cluster": "kafka-cl01", "topic": "orders", "data": {}}`)))
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id": "2", "cluster": "kafka-cl01"}`)))
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"id": "3", "topc": "orders"}`)))
+
+	replies := map[string]wsReply{}
+	for i := 0; i < 3; i++ {
+		var reply wsReply
+		assert.Nil(t, conn.ReadJSON(&reply))
+		replies[reply.ID] = reply
+	}
+
+	assert.Equal(t, wsReply{Type: "ack", ID: "1", Message: "orders", Cluster: "kafka-cl01"}, replies["1"])
+	assert.Equal(t, "error", replies["2"].Type)
+	assert.Equal(t, codeValidationFailed, replies["2"].Code)
+	// the correlation ID of a frame that can't be decoded is unknown
+	assert.Equal(t, codeUnknownField, replies[""].Code)
+	assert.Equal(t, 1, cp.count)
+}
+
+func TestPublishWebSocketAuth(t *testing.T) {
+	setup()
+	Config.EnableAPIAuth = true
+	defer func() {
+		Config.EnableAPIAuth = false
+	}()
+
+	event := []byte(`{"id": "1", "cluster": "kafka-cl01", "topic": "orders", "data": {}}`)
+
+	// unauthenticated events close the connection
+	conn := dialWebSocket(t, &countingProducer{}, nil)
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, event))
+
+	var reply wsReply
+	assert.Nil(t, conn.ReadJSON(&reply))
+	assert.Equal(t, codeUnauthorized, reply.Code)
+
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+
+	// authenticate with a frame
+	conn = dialWebSocket(t, &countingProducer{}, nil)
+	assert.Nil(t, conn.WriteJSON(wsRequest{Type: "auth", ID: "a", APIToken: Secrets.APIToken}))
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, event))
+
+	reply = wsReply{}
+	assert.Nil(t, conn.ReadJSON(&reply))
+	assert.Equal(t, wsReply{Type: "ack", ID: "a"}, reply)
+
+	reply = wsReply{}
+	assert.Nil(t, conn.ReadJSON(&reply))
+	assert.Equal(t, wsReply{Type: "ack", ID: "1", Message: "orders", Cluster: "kafka-cl01"}, reply)
+
+	// or with the header
+	conn = dialWebSocket(t, &countingProducer{}, http.Header{"X-Api-Token": {Secrets.APIToken}})
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, event))
+
+	reply = wsReply{}
+	assert.Nil(t, conn.ReadJSON(&reply))
+	assert.Equal(t, "ack", reply.Type)
+}
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	Config.WebSocket.AllowedOrigins = []string{"https://app.example.com"}
+	defer func() {
+		Config.WebSocket.AllowedOrigins = nil
+	}()
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://proxy.example.com", true},
+		{"https://app.example.com", true},
+		{"https://evil.example.com", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://proxy.example.com/events/ws", nil)
+		if len(tt.origin) > 0 {
+			r.Header.Set("Origin", tt.origin)
+		}
+
+		assert.Equal(t, tt.want, checkWebSocketOrigin(r), tt.origin)
+	}
+}
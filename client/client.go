@@ -0,0 +1,550 @@
+// Package client is a Go client of the kafka-producer-proxy API.
+//
+//	c, err := client.New("https://kafka-producer-proxy.example.com", client.WithAPIToken(token))
+//	if err != nil {
+//		return err
+//	}
+//
+//	result, err := c.Publish(ctx, client.Event{Cluster: "kafka-cl01", Topic: "orders", Key: id, Data: order})
+//
+// Code publishing events can depend on the API interface and use the Fake in its unit tests.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiTokenHeader       = "X-API-TOKEN"
+	idempotencyKeyHeader = "Idempotency-Key"
+	jsonContentType      = "application/json"
+	ndjsonContentType    = "application/x-ndjson"
+)
+
+// API is implemented by the Client and the Fake.
+type API interface {
+	// Publish produces the event, retrying retriable errors.
+	Publish(ctx context.Context, event Event) (*PublishResult, error)
+	// PublishBatch produces the events with a single streaming request, returning a result per event
+	// in the order of the events. Only the events that failed with a retriable error are retried.
+	PublishBatch(ctx context.Context, events []Event) ([]BatchResult, error)
+	// Clusters returns the clusters the caller may publish to.
+	Clusters(ctx context.Context) ([]string, error)
+	// Health returns an error if any cluster of the proxy is unhealthy.
+	Health(ctx context.Context) error
+}
+
+// Event is published to the topic of the cluster.
+type Event struct {
+	Cluster    string                 `json:"cluster"`
+	Topic      string                 `json:"topic"`
+	Key        interface{}            `json:"key"`
+	Data       map[string]interface{} `json:"data"`
+	Durability string                 `json:"durability,omitempty"`
+	// IdempotencyKey makes retries of Publish safe, without it a retry after the connection was
+	// lost may produce the event twice. It isn't sent by PublishBatch.
+	IdempotencyKey string `json:"-"`
+}
+
+// PublishResult is returned for a published event.
+type PublishResult struct {
+	// Message is the topic, partition and offset the event was written to.
+	Message string `json:"message,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+	// Spooled is true if the cluster was unavailable and the proxy will produce the event later.
+	Spooled bool `json:"-"`
+}
+
+// BatchResult is the result of an event of PublishBatch, Err is nil if it was published.
+type BatchResult struct {
+	PublishResult
+	Err error
+}
+
+// Client calls the API of a kafka-producer-proxy, it is safe for concurrent use.
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	tlsConfig   *tls.Config
+	apiToken    string
+	bearerToken string
+	retry       RetryPolicy
+}
+
+var _ API = (*Client)(nil)
+
+// Option configures the Client.
+type Option func(c *Client) error
+
+// WithAPIToken sets the X-API-TOKEN header of the requests.
+func WithAPIToken(token string) Option {
+	return func(c *Client) error {
+		c.apiToken = token
+		return nil
+	}
+}
+
+// WithBearerToken sets the Authorization header of the requests, for proxies behind a gateway
+// that authenticates callers.
+func WithBearerToken(token string) Option {
+	return func(c *Client) error {
+		c.bearerToken = token
+		return nil
+	}
+}
+
+// WithClientCertificate authenticates the client with the certificate and key files, for mTLS.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(c *Client) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("error loading client certificate: %w", err)
+		}
+
+		c.tls().Certificates = append(c.tls().Certificates, cert)
+
+		return nil
+	}
+}
+
+// WithRootCAs verifies the proxy's certificate with the CA certificates of the PEM file
+// instead of the system's.
+func WithRootCAs(caFile string) Option {
+	return func(c *Client) error {
+		b, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("error reading CA certificates: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no CA certificates found in %s", caFile)
+		}
+
+		c.tls().RootCAs = pool
+
+		return nil
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client, WithClientCertificate and WithRootCAs
+// configure its transport if it is an *http.Transport.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		c.httpClient = hc
+		return nil
+	}
+}
+
+// WithRetryPolicy replaces the DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) error {
+		c.retry = p
+		return nil
+	}
+}
+
+// New returns a Client of the proxy at the base URL.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy,
+	}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.tlsConfig != nil {
+		var transport *http.Transport
+
+		switch t := c.httpClient.Transport.(type) {
+		case nil:
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			transport = t.Clone()
+		default:
+			return nil, fmt.Errorf("TLS options require an *http.Transport, got %T", t)
+		}
+
+		transport.TLSClientConfig = c.tlsConfig
+
+		hc := *c.httpClient
+		hc.Transport = transport
+		c.httpClient = &hc
+	}
+
+	return c, nil
+}
+
+func (c *Client) tls() *tls.Config {
+	if c.tlsConfig == nil {
+		c.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	return c.tlsConfig
+}
+
+// Publish produces the event, retrying retriable errors. Set the IdempotencyKey of the event
+// to avoid duplicates when a response is lost and the request is retried.
+func (c *Client) Publish(ctx context.Context, event Event) (*PublishResult, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding event: %w", err)
+	}
+
+	var result *PublishResult
+
+	err = c.retry.do(ctx, func() error {
+		req, err := c.newRequest(ctx, http.MethodPost, "/events", jsonContentType, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		if len(event.IdempotencyKey) > 0 {
+			req.Header.Set(idempotencyKeyHeader, event.IdempotencyKey)
+		}
+
+		resp, err := c.do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		var pr PublishResult
+		if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+
+		pr.Spooled = resp.StatusCode == http.StatusAccepted
+		result = &pr
+
+		return nil
+	})
+
+	return result, err
+}
+
+// streamResult is the result of a line of /events/stream.
+type streamResult struct {
+	Line      int          `json:"line"`
+	Status    int          `json:"status"`
+	Message   string       `json:"message,omitempty"`
+	Cluster   string       `json:"cluster,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Retriable bool         `json:"retriable,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
+
+// PublishBatch produces the events with a single streaming request, returning a result per event
+// in the order of the events. The events that failed with a retriable error, or whose result was
+// lost with the connection, are sent again with the next attempt, which may produce them twice.
+// Events that can't be encoded fail without being sent. The returned error is only set if the
+// request itself failed, such as when the token is rejected.
+func (c *Client) PublishBatch(ctx context.Context, events []Event) ([]BatchResult, error) {
+	results := make([]BatchResult, len(events))
+	lines := make([][]byte, len(events))
+
+	// pending are the indexes of the events without a final result
+	pending := []int{}
+	for i, event := range events {
+		b, err := json.Marshal(event)
+		if err != nil {
+			results[i].Err = fmt.Errorf("error encoding event %d: %w", i, err)
+			continue
+		}
+
+		lines[i] = append(b, '\n')
+		pending = append(pending, i)
+	}
+
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	err := c.retry.do(ctx, func() error {
+		var err error
+
+		pending, err = c.publishStream(ctx, lines, pending, results)
+
+		return err
+	})
+
+	if err == nil {
+		return results, nil
+	}
+
+	// the request failed before any event had a result
+	streamed := false
+	for _, r := range results {
+		streamed = streamed || r.Err != nil || len(r.Message) > 0
+	}
+
+	if !streamed {
+		return nil, err
+	}
+
+	for _, i := range pending {
+		if results[i].Err == nil {
+			results[i].Err = err
+		}
+	}
+
+	return results, nil
+}
+
+// publishStream sends the encoded lines of the pending events, setting their results, and returns
+// the events to retry.
+func (c *Client) publishStream(ctx context.Context, lines [][]byte, pending []int, results []BatchResult) ([]int, error) {
+	body := &bytes.Buffer{}
+	for _, i := range pending {
+		body.Write(lines[i])
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/events/stream", ndjsonContentType, body)
+	if err != nil {
+		return pending, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return pending, err
+	}
+	defer resp.Body.Close()
+
+	seen := make([]bool, len(pending))
+	var retry *Error
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var sr streamResult
+		if err := json.Unmarshal(scanner.Bytes(), &sr); err != nil {
+			return pending, fmt.Errorf("error decoding stream result: %w", err)
+		}
+
+		// lines are numbered from 1 and every event is encoded on a single line
+		if sr.Line < 1 || sr.Line > len(pending) {
+			continue
+		}
+
+		seen[sr.Line-1] = true
+		r := &results[pending[sr.Line-1]]
+
+		if sr.Status >= 200 && sr.Status < 300 {
+			*r = BatchResult{PublishResult: PublishResult{sr.Message, sr.Cluster, sr.Status == http.StatusAccepted}}
+			continue
+		}
+
+		e := &Error{StatusCode: sr.Status, Code: sr.Code, Message: sr.Message, Retriable: sr.Retriable, Fields: sr.Fields}
+		*r = BatchResult{Err: e}
+
+		if e.Retriable {
+			retry = e
+		}
+	}
+
+	retries := []int{}
+	for n, i := range pending {
+		if !seen[n] || IsRetriable(results[i].Err) {
+			retries = append(retries, i)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return retries, &transportError{fmt.Errorf("error reading stream results: %w", err)}
+	}
+
+	if retry != nil {
+		return retries, retry
+	}
+
+	if len(retries) > 0 {
+		return retries, &Error{StatusCode: resp.StatusCode, Code: CodeInternal, Message: "stream ended before every event had a result", Retriable: true}
+	}
+
+	return retries, nil
+}
+
+// Clusters returns the clusters the caller may publish to.
+func (c *Client) Clusters(ctx context.Context) ([]string, error) {
+	var body struct {
+		Clusters []string `json:"clusters"`
+	}
+
+	if err := c.getJSON(ctx, "/clusters", &body); err != nil {
+		return nil, err
+	}
+
+	return body.Clusters, nil
+}
+
+// Partition of a topic, identified by broker IDs.
+type Partition struct {
+	ID       int32   `json:"id"`
+	Leader   int32   `json:"leader"`
+	Replicas []int32 `json:"replicas"`
+	ISR      []int32 `json:"isr"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// TopicInfo describes the partitions and key configs of a topic.
+type TopicInfo struct {
+	Name                      string            `json:"name"`
+	Partitions                int               `json:"partitions"`
+	ReplicationFactor         int               `json:"replicationFactor"`
+	UnderReplicatedPartitions int               `json:"underReplicatedPartitions"`
+	PartitionDetails          []Partition       `json:"partitionDetails"`
+	Configs                   map[string]string `json:"configs"`
+}
+
+// Topic describes the topic of the cluster. It isn't part of the API interface, since publishers
+// rarely need it.
+func (c *Client) Topic(ctx context.Context, cluster, topic string) (*TopicInfo, error) {
+	var info TopicInfo
+
+	if err := c.getJSON(ctx, fmt.Sprintf("/clusters/%s/topics/%s", url.PathEscape(cluster), url.PathEscape(topic)), &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// getJSON decodes the response of the GET into v, retrying retriable errors.
+func (c *Client) getJSON(ctx context.Context, path string, v interface{}) error {
+	return c.retry.do(ctx, func() error {
+		req, err := c.newRequest(ctx, http.MethodGet, path, "", nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := c.do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Health returns an error if any cluster of the proxy is unhealthy. It isn't retried, since the
+// proxy checks every cluster on each call.
+func (c *Client) Health(ctx context.Context) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/health", "", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	// the errors of the health check are one per line, which isn't valid JSON
+	msg := strings.TrimSpace(string(b))
+	msg = strings.TrimSuffix(strings.TrimPrefix(msg, `{"errors": "`), `"}`)
+
+	return &Error{StatusCode: resp.StatusCode, Code: CodeClusterUnavailable, Message: strings.TrimSpace(msg)}
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	req.Header.Set("Accept", jsonContentType)
+
+	if len(c.apiToken) > 0 {
+		req.Header.Set(apiTokenHeader, c.apiToken)
+	}
+
+	if len(c.bearerToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+
+	return req, nil
+}
+
+// do sends the request, returning an *Error for error responses.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &transportError{err}
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	return nil, newError(resp)
+}
+
+// newError decodes the error response, responses that aren't from the proxy, such as those of a
+// gateway, are retriable if their status usually is.
+func newError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode}
+
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(b, e); err != nil || len(e.Code) == 0 {
+		e.Code = ""
+		e.Message = strings.TrimSpace(string(b))
+		if len(e.Message) == 0 {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			e.Retriable = true
+		}
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return e
+}
@@ -0,0 +1,352 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fastRetries = WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+
+func newTestClient(t *testing.T, h http.HandlerFunc, opts ...Option) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c, err := New(srv.URL, append([]Option{fastRetries}, opts...)...)
+	assert.Nil(t, err)
+
+	return c
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("kafka-producer-proxy:8080")
+	assert.NotNil(t, err)
+
+	_, err = New("http://localhost:8080", WithRootCAs("testdata/missing.pem"))
+	assert.NotNil(t, err)
+
+	c, err := New("https://localhost:8080/")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://localhost:8080", c.baseURL.String())
+}
+
+func TestPublish(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/events", r.URL.Path)
+		assert.Equal(t, "TestApiToken", r.Header.Get("X-API-TOKEN"))
+		assert.Equal(t, "Bearer jwt", r.Header.Get("Authorization"))
+		assert.Equal(t, "order-1", r.Header.Get("Idempotency-Key"))
+
+		var e Event
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&e))
+		assert.Equal(t, "orders", e.Topic)
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message":"orders[0]@1","cluster":"kafka-cl01"}`))
+	}, WithAPIToken("TestApiToken"), WithBearerToken("jwt"))
+
+	result, err := c.Publish(context.Background(), Event{
+		Cluster:        "kafka-cl01",
+		Topic:          "orders",
+		Key:            1,
+		Data:           map[string]interface{}{"id": 1},
+		IdempotencyKey: "order-1",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &PublishResult{Message: "orders[0]@1", Cluster: "kafka-cl01", Spooled: true}, result)
+}
+
+func TestPublishRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		attempts int32
+		code     string
+	}{
+		{
+			name:     "retriable",
+			status:   http.StatusServiceUnavailable,
+			body:     `{"code":"cluster_unavailable","message":"cluster is unavailable","retriable":true}`,
+			attempts: 3,
+			code:     CodeClusterUnavailable,
+		},
+		{
+			name:     "not retriable",
+			status:   http.StatusBadRequest,
+			body:     `{"code":"validation_failed","message":"request failed validation","retriable":false,"fields":[{"field":"topic","code":"required","message":"topic is required"}]}`,
+			attempts: 1,
+			code:     CodeValidationFailed,
+		},
+		{
+			name:     "gateway",
+			status:   http.StatusBadGateway,
+			body:     `bad gateway`,
+			attempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := c.Publish(context.Background(), Event{Cluster: "kafka-cl01", Topic: "orders"})
+
+			var e *Error
+			assert.True(t, errors.As(err, &e))
+			assert.Equal(t, tt.status, e.StatusCode)
+			assert.Equal(t, tt.code, e.Code)
+			assert.Equal(t, tt.attempts, atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestPublishRetrySucceeds(t *testing.T) {
+	var attempts int32
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code":"rate_limited","message":"rate limit exceeded","retriable":true}`))
+			return
+		}
+
+		w.Write([]byte(`{"message":"orders[0]@1","cluster":"kafka-cl01"}`))
+	})
+
+	result, err := c.Publish(context.Background(), Event{Cluster: "kafka-cl01", Topic: "orders"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "orders[0]@1", result.Message)
+	assert.False(t, result.Spooled)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestPublishNotRetriedAfterSuccess(t *testing.T) {
+	var attempts int32
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Write([]byte(`not json`))
+	})
+
+	// the event was produced, sending it again would duplicate it
+	_, err := c.Publish(context.Background(), Event{Cluster: "kafka-cl01", Topic: "orders"})
+
+	assert.NotNil(t, err)
+	assert.False(t, IsRetriable(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestPublishRetriesTransportErrors(t *testing.T) {
+	var attempts int32
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		assert.Nil(t, err)
+		conn.Close()
+	})
+
+	_, err := c.Publish(context.Background(), Event{Cluster: "kafka-cl01", Topic: "orders"})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestPublishBatch(t *testing.T) {
+	var attempts int32
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/events/stream", r.URL.Path)
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+
+		attempt := atomic.AddInt32(&attempts, 1)
+
+		scanner := bufio.NewScanner(r.Body)
+		enc := json.NewEncoder(w)
+
+		line := 0
+		for scanner.Scan() {
+			line++
+
+			var e Event
+			assert.Nil(t, json.Unmarshal(scanner.Bytes(), &e))
+
+			switch {
+			case e.Topic == "missing":
+				enc.Encode(streamResult{Line: line, Status: http.StatusNotFound, Code: CodeTopicNotFound, Message: "topic missing does not exist"})
+			case e.Topic == "busy" && attempt == 1:
+				enc.Encode(streamResult{Line: line, Status: http.StatusServiceUnavailable, Code: CodeProducerBusy, Message: "producer is busy", Retriable: true})
+			default:
+				enc.Encode(streamResult{Line: line, Status: http.StatusOK, Message: fmt.Sprintf("%s[0]@%d", e.Topic, attempt), Cluster: e.Cluster})
+			}
+		}
+	})
+
+	results, err := c.PublishBatch(context.Background(), []Event{
+		{Cluster: "kafka-cl01", Topic: "orders"},
+		{Cluster: "kafka-cl01", Topic: "busy"},
+		{Cluster: "kafka-cl01", Topic: "missing"},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, "orders[0]@1", results[0].Message)
+
+	assert.Nil(t, results[1].Err)
+	assert.Equal(t, "busy[0]@2", results[1].Message)
+
+	assert.Equal(t, CodeTopicNotFound, ErrorCode(results[2].Err))
+	assert.False(t, IsRetriable(results[2].Err))
+}
+
+func TestPublishBatchUnencodable(t *testing.T) {
+	var lines int32
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		scanner := bufio.NewScanner(r.Body)
+		enc := json.NewEncoder(w)
+
+		for scanner.Scan() {
+			line := atomic.AddInt32(&lines, 1)
+			enc.Encode(streamResult{Line: int(line), Status: http.StatusOK, Message: "orders[0]@1"})
+		}
+	})
+
+	results, err := c.PublishBatch(context.Background(), []Event{
+		{Cluster: "kafka-cl01", Topic: "orders", Data: map[string]interface{}{"value": math.NaN()}},
+		{Cluster: "kafka-cl01", Topic: "orders"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lines))
+
+	assert.NotNil(t, results[0].Err)
+	assert.False(t, IsRetriable(results[0].Err))
+
+	assert.Nil(t, results[1].Err)
+	assert.Equal(t, "orders[0]@1", results[1].Message)
+
+	// nothing is sent when no event can be encoded
+	results, err = c.PublishBatch(context.Background(), []Event{{Data: map[string]interface{}{"value": math.Inf(1)}}})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, results[0].Err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lines))
+}
+
+func TestPublishBatchUnauthorized(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"unauthorized","message":"api token is missing or invalid","retriable":false}`))
+	})
+
+	results, err := c.PublishBatch(context.Background(), []Event{{Cluster: "kafka-cl01", Topic: "orders"}})
+
+	assert.Nil(t, results)
+	assert.Equal(t, CodeUnauthorized, ErrorCode(err))
+}
+
+func TestClusters(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/clusters", r.URL.Path)
+		w.Write([]byte(`{"clusters":["kafka-cl01","kafka-cl02"]}`))
+	})
+
+	clusters, err := c.Clusters(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"kafka-cl01", "kafka-cl02"}, clusters)
+}
+
+func TestTopic(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/clusters/kafka-cl01/topics/orders", r.URL.Path)
+		w.Write([]byte(`{"name":"orders","partitions":1,"replicationFactor":3,"underReplicatedPartitions":0,` +
+			`"partitionDetails":[{"id":0,"leader":1,"replicas":[1,2,3],"isr":[1,2,3]}],"configs":{"retention.ms":"604800000"}}`))
+	})
+
+	info, err := c.Topic(context.Background(), "kafka-cl01", "orders")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, info.ReplicationFactor)
+	assert.Equal(t, []int32{1, 2, 3}, info.PartitionDetails[0].ISR)
+	assert.Equal(t, "604800000", info.Configs["retention.ms"])
+}
+
+func TestHealth(t *testing.T) {
+	var unhealthy int32
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&unhealthy) == 0 {
+			w.Write([]byte("OK"))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("{\"errors\": \"cluster kafka-cl01 is unhealthy\n\"}"))
+	})
+
+	assert.Nil(t, c.Health(context.Background()))
+
+	atomic.StoreInt32(&unhealthy, 1)
+	err := c.Health(context.Background())
+
+	var e *Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, "cluster kafka-cl01 is unhealthy", e.Message)
+}
+
+func TestRetryAfter(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	attempts := 0
+	start := time.Now()
+
+	err := p.do(context.Background(), func() error {
+		attempts++
+		return &Error{Code: CodeRateLimited, Retriable: true, RetryAfter: 10 * time.Millisecond}
+	})
+
+	assert.Equal(t, CodeRateLimited, ErrorCode(err))
+	assert.Equal(t, 2, attempts)
+	assert.Less(t, int64(time.Since(start)), int64(time.Hour))
+}
+
+func TestRetryStopsWithContext(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := p.do(ctx, func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error codes returned by the proxy, see the Errors section of the README.
+const (
+	CodeInvalidJSON         = "invalid_json"
+	CodeUnknownField        = "unknown_field"
+	CodeValidationFailed    = "validation_failed"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeClusterNotFound     = "cluster_not_found"
+	CodeTopicNotFound       = "topic_not_found"
+	CodeNotEnabled          = "not_enabled"
+	CodeIdempotencyConflict = "idempotency_conflict"
+	CodeTopicExists         = "topic_exists"
+	CodeBodyTooLarge        = "body_too_large"
+	CodeMessageTooLarge     = "message_too_large"
+	CodeUnsupportedMedia    = "unsupported_media_type"
+	CodeRateLimited         = "rate_limited"
+	CodeProducerBusy        = "producer_busy"
+	CodeClusterUnavailable  = "cluster_unavailable"
+	CodeTimeout             = "timeout"
+	CodeInternal            = "internal_error"
+)
+
+// FieldError describes why a field of the request is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error response of the proxy.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	// Details are only returned if the proxy sets exposeErrorDetails.
+	Details string `json:"error,omitempty"`
+	// Retriable indicates the same request may succeed if sent again later.
+	Retriable bool         `json:"retriable"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+	// RetryAfter is the Retry-After header of the response.
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("kafka-producer-proxy: %d %s: %s", e.StatusCode, e.Code, e.Message)
+
+	for _, f := range e.Fields {
+		msg = fmt.Sprintf("%s; %s: %s", msg, f.Field, f.Message)
+	}
+
+	if len(e.Details) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, e.Details)
+	}
+
+	return msg
+}
+
+// IsRetriable returns true if the error is a retriable error of the proxy.
+func IsRetriable(err error) bool {
+	var e *Error
+
+	return errors.As(err, &e) && e.Retriable
+}
+
+// ErrorCode returns the code of the proxy's error, empty for other errors.
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return ""
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Fake is an in-memory API for unit tests of code publishing events. It records the published
+// events and fails them like the proxy would when the cluster or topic is unknown.
+//
+//	fake := client.NewFake("kafka-cl01")
+//	svc := NewOrderService(fake)
+//	...
+//	assert.Len(t, fake.Events(), 1)
+type Fake struct {
+	mu        sync.Mutex
+	clusters  []string
+	events    []Event
+	offsets   map[string]int64
+	failures  map[string]error
+	unhealthy error
+}
+
+var _ API = (*Fake)(nil)
+
+// NewFake returns a Fake accepting events for any topic of the clusters.
+func NewFake(clusters ...string) *Fake {
+	f := &Fake{clusters: clusters}
+	f.Reset()
+
+	return f
+}
+
+// FailTopic fails the events of the topic with the error, nil stops failing them.
+func (f *Fake) FailTopic(topic string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.failures, topic)
+		return
+	}
+
+	f.failures[topic] = err
+}
+
+// SetHealth sets the error returned by Health.
+func (f *Fake) SetHealth(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.unhealthy = err
+}
+
+// Events returns a copy of the published events, in the order they were published.
+func (f *Fake) Events() []Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := make([]Event, len(f.events))
+	copy(events, f.events)
+
+	return events
+}
+
+// Reset forgets the published events and failures.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.events = nil
+	f.offsets = map[string]int64{}
+	f.failures = map[string]error{}
+	f.unhealthy = nil
+}
+
+// Publish records the event.
+func (f *Fake) Publish(ctx context.Context, event Event) (*PublishResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.publish(event)
+}
+
+// PublishBatch records the events, returning a result per event.
+func (f *Fake) PublishBatch(ctx context.Context, events []Event) ([]BatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	results := make([]BatchResult, len(events))
+	for i, event := range events {
+		result, err := f.publish(event)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i].PublishResult = *result
+	}
+
+	return results, nil
+}
+
+func (f *Fake) publish(event Event) (*PublishResult, error) {
+	if len(event.Cluster) == 0 || len(event.Topic) == 0 {
+		fields := []FieldError{}
+		if len(event.Cluster) == 0 {
+			fields = append(fields, FieldError{"cluster", "required", "cluster is required"})
+		}
+		if len(event.Topic) == 0 {
+			fields = append(fields, FieldError{"topic", "required", "topic is required"})
+		}
+
+		return nil, &Error{StatusCode: http.StatusBadRequest, Code: CodeValidationFailed, Message: "request failed validation", Fields: fields}
+	}
+
+	if !f.hasCluster(event.Cluster) {
+		return nil, &Error{StatusCode: http.StatusNotFound, Code: CodeClusterNotFound, Message: fmt.Sprintf("cluster %s does not exist", event.Cluster)}
+	}
+
+	if err := f.failures[event.Topic]; err != nil {
+		return nil, err
+	}
+
+	offset := f.offsets[event.Topic]
+	f.offsets[event.Topic]++
+	f.events = append(f.events, event)
+
+	return &PublishResult{Message: fmt.Sprintf("%s[0]@%d", event.Topic, offset), Cluster: event.Cluster}, nil
+}
+
+func (f *Fake) hasCluster(cluster string) bool {
+	for _, c := range f.clusters {
+		if c == cluster {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Clusters returns the clusters of the Fake.
+func (f *Fake) Clusters(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	clusters := make([]string, len(f.clusters))
+	copy(clusters, f.clusters)
+
+	return clusters, nil
+}
+
+// Health returns the error set by SetHealth.
+func (f *Fake) Health(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.unhealthy
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("kafka-cl01")
+
+	result, err := fake.Publish(ctx, Event{Cluster: "kafka-cl01", Topic: "orders", Key: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, &PublishResult{Message: "orders[0]@0", Cluster: "kafka-cl01"}, result)
+
+	_, err = fake.Publish(ctx, Event{Cluster: "kafka-cl02", Topic: "orders"})
+	assert.Equal(t, CodeClusterNotFound, ErrorCode(err))
+
+	_, err = fake.Publish(ctx, Event{Cluster: "kafka-cl01"})
+	assert.Equal(t, CodeValidationFailed, ErrorCode(err))
+
+	fake.FailTopic("payments", &Error{Code: CodeProducerBusy, Retriable: true})
+
+	results, err := fake.PublishBatch(ctx, []Event{
+		{Cluster: "kafka-cl01", Topic: "orders", Key: 2},
+		{Cluster: "kafka-cl01", Topic: "payments", Key: 3},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "orders[0]@1", results[0].Message)
+	assert.True(t, IsRetriable(results[1].Err))
+
+	events := fake.Events()
+	assert.Len(t, events, 2)
+	assert.Equal(t, 2, events[1].Key)
+
+	clusters, err := fake.Clusters(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"kafka-cl01"}, clusters)
+
+	fake.SetHealth(errors.New("unhealthy"))
+	assert.NotNil(t, fake.Health(ctx))
+
+	fake.Reset()
+	assert.Empty(t, fake.Events())
+	assert.Nil(t, fake.Health(ctx))
+}
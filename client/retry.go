@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy retries requests that failed with a retriable error or couldn't reach the proxy,
+// with an exponential backoff. The Retry-After of the response is honored when present.
+//
+// A request that couldn't reach the proxy, or whose response was lost, may still have been
+// produced, so retries can duplicate events. Set the IdempotencyKey of the events passed to
+// Publish to have the proxy deduplicate them.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, 1 disables retries.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is used unless WithRetryPolicy is set.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// backoff returns the wait before the next attempt, half of it jittered.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff << uint(attempt)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// transportError is an error sending the request or reading its response, the proxy may
+// not have received the request.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// retriable returns true if the request may be sent again. Errors after the proxy accepted the
+// request, such as a response that can't be decoded, aren't retried since the events were
+// already produced.
+func retriable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Retriable
+	}
+
+	var te *transportError
+
+	return errors.As(err, &te)
+}
+
+// do calls f until it succeeds, fails with an error that isn't retriable or the attempts are exhausted.
+func (p RetryPolicy) do(ctx context.Context, f func() error) error {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	var err error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err = f(); err == nil || !retriable(ctx, err) || attempt == attempts-1 {
+			return err
+		}
+
+		wait := p.backoff(attempt)
+
+		var e *Error
+		if errors.As(err, &e) && e.RetryAfter > 0 {
+			wait = e.RetryAfter
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+
+	return err
+}
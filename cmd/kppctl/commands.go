@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/traviisd/kafka-producer-proxy/api"
+)
+
+// print writes v as JSON, or as the table written by the function.
+func (c *cli) print(v interface{}, table func(w *tabwriter.Writer)) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	table(w)
+
+	return w.Flush()
+}
+
+func (c *cli) clusters(ctx context.Context) error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	clusters, err := cl.Clusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.print(map[string][]string{"clusters": clusters}, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "CLUSTER")
+		for _, cluster := range clusters {
+			fmt.Fprintln(w, cluster)
+		}
+	})
+}
+
+func (c *cli) health(ctx context.Context) error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	health := struct {
+		Healthy bool   `json:"healthy"`
+		Error   string `json:"error,omitempty"`
+	}{Healthy: true}
+
+	herr := cl.Health(ctx)
+	if herr != nil {
+		health.Healthy = false
+		health.Error = herr.Error()
+	}
+
+	if err := c.print(health, func(w *tabwriter.Writer) {
+		if health.Healthy {
+			fmt.Fprintln(w, "healthy")
+			return
+		}
+
+		fmt.Fprintf(w, "unhealthy\t%s\n", health.Error)
+	}); err != nil {
+		return err
+	}
+
+	if herr != nil {
+		return fmt.Errorf("proxy is unhealthy")
+	}
+
+	return nil
+}
+
+func (c *cli) topics(ctx context.Context, args []string) error {
+	if len(args) != 3 || args[0] != "describe" {
+		fmt.Fprintln(c.stderr, "Usage: kppctl topics describe <cluster> <topic>")
+		return errUsage
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	info, err := cl.Topic(ctx, args[1], args[2])
+	if err != nil {
+		return err
+	}
+
+	return c.print(info, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Name:\t%s\n", info.Name)
+		fmt.Fprintf(w, "Partitions:\t%d\n", info.Partitions)
+		fmt.Fprintf(w, "Replication factor:\t%d\n", info.ReplicationFactor)
+		fmt.Fprintf(w, "Under-replicated partitions:\t%d\n", info.UnderReplicatedPartitions)
+
+		fmt.Fprintln(w, "\nPARTITION\tLEADER\tREPLICAS\tISR\tERROR")
+		for _, p := range info.PartitionDetails {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", p.ID, p.Leader, brokerList(p.Replicas), brokerList(p.ISR), p.Error)
+		}
+
+		names := make([]string, 0, len(info.Configs))
+		for name := range info.Configs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(w, "\nCONFIG\tVALUE")
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%s\n", name, info.Configs[name])
+		}
+	})
+}
+
+func brokerList(ids []int32) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprint(id)
+	}
+
+	return strings.Join(s, ",")
+}
+
+// validateConfig checks an app-config.json locally, along with the secrets of the -secrets path
+// when set. It doesn't call the proxy.
+func (c *cli) validateConfig(args []string) error {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+
+	secrets := fs.String("secrets", "", "path of the secrets.json and internal-ca.json to cross-reference")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "Usage: kppctl validate-config [-secrets path] <app-config.json>")
+		return errUsage
+	}
+
+	file := fs.Arg(0)
+
+	var errs []error
+	if len(*secrets) > 0 {
+		errs = api.ValidateConfig(file, *secrets)
+	} else {
+		errs = api.CheckAppConfig(file)
+	}
+
+	problems := []string{}
+	for _, err := range errs {
+		problems = append(problems, err.Error())
+	}
+
+	result := struct {
+		File     string   `json:"file"`
+		Valid    bool     `json:"valid"`
+		Problems []string `json:"problems"`
+	}{file, len(problems) == 0, problems}
+
+	if err := c.print(result, func(w *tabwriter.Writer) {
+		if result.Valid {
+			fmt.Fprintf(w, "%s is valid\n", result.File)
+			return
+		}
+
+		for _, p := range problems {
+			fmt.Fprintln(w, p)
+		}
+	}); err != nil {
+		return err
+	}
+
+	if !result.Valid {
+		return fmt.Errorf("%s is invalid", result.File)
+	}
+
+	return nil
+}
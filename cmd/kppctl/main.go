@@ -0,0 +1,156 @@
+// Command kppctl publishes events and inspects clusters through a kafka-producer-proxy.
+//
+//	kppctl [flags] <command> [arguments]
+//
+// The commands are publish, clusters, health, topics describe and validate-config, run
+// kppctl -h for their usage.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/traviisd/kafka-producer-proxy/client"
+)
+
+const usage = `Usage: kppctl [flags] <command> [arguments]
+
+Commands:
+  publish [-cluster c] [-topic t] [-key k] [-f file] [data...]
+                          publish the JSON data of the arguments, or the NDJSON events of
+                          the file, or of stdin when there are neither
+  clusters                list the clusters
+  health                  check the health of the clusters
+  topics describe <cluster> <topic>
+                          describe the partitions and configs of the topic
+  validate-config [-secrets path] <file>
+                          validate an app-config.json, and the secrets of the path
+
+Flags:
+`
+
+// errUsage is returned for invalid arguments, the usage was already printed.
+var errUsage = errors.New("invalid usage")
+
+// cli holds the global flags and streams of a run.
+type cli struct {
+	url     string
+	token   string
+	bearer  string
+	tlsCert string
+	tlsKey  string
+	tlsCA   string
+	output  string
+	timeout time.Duration
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command of the arguments and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("kppctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&c.url, "url", envOr("KPP_URL", "http://localhost:39000"), "base URL of the proxy, $KPP_URL")
+	fs.StringVar(&c.token, "token", os.Getenv("KPP_API_TOKEN"), "API token sent as X-API-TOKEN, $KPP_API_TOKEN")
+	fs.StringVar(&c.bearer, "bearer", os.Getenv("KPP_BEARER_TOKEN"), "bearer token for proxies behind a gateway, $KPP_BEARER_TOKEN")
+	fs.StringVar(&c.tlsCert, "tls-cert", "", "client certificate file for mTLS")
+	fs.StringVar(&c.tlsKey, "tls-key", "", "client key file for mTLS")
+	fs.StringVar(&c.tlsCA, "tls-ca", "", "CA certificates file verifying the proxy")
+	fs.StringVar(&c.output, "o", "table", "output format, table or json")
+	fs.DurationVar(&c.timeout, "timeout", 30*time.Second, "timeout of the command")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(stderr, "invalid output format %q, must be table or json\n", c.output)
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var err error
+
+	switch cmd, args := fs.Arg(0), fs.Args()[1:]; cmd {
+	case "publish":
+		err = c.publish(ctx, args)
+	case "clusters":
+		err = c.clusters(ctx)
+	case "health":
+		err = c.health(ctx)
+	case "topics":
+		err = c.topics(ctx, args)
+	case "validate-config":
+		err = c.validateConfig(args)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", cmd)
+		fs.Usage()
+		return 2
+	}
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+}
+
+// client returns a client of the proxy configured from the flags.
+func (c *cli) client() (*client.Client, error) {
+	opts := []client.Option{}
+
+	if len(c.token) > 0 {
+		opts = append(opts, client.WithAPIToken(c.token))
+	}
+
+	if len(c.bearer) > 0 {
+		opts = append(opts, client.WithBearerToken(c.bearer))
+	}
+
+	if len(c.tlsCert) > 0 || len(c.tlsKey) > 0 {
+		opts = append(opts, client.WithClientCertificate(c.tlsCert, c.tlsKey))
+	}
+
+	if len(c.tlsCA) > 0 {
+		opts = append(opts, client.WithRootCAs(c.tlsCA))
+	}
+
+	return client.New(c.url, opts...)
+}
+
+func envOr(key, value string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+
+	return value
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestProxy serves the routes used by kppctl, events of the topic "missing" fail.
+func newTestProxy(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "TestApiToken", r.Header.Get("X-API-TOKEN"))
+
+		var e map[string]interface{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&e))
+
+		if e["topic"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"topic_not_found","message":"topic missing does not exist","retriable":false}`))
+			return
+		}
+
+		fmt.Fprintf(w, `{"message":"%s[0]@0","cluster":"%s"}`, e["topic"], e["cluster"])
+	})
+
+	mux.HandleFunc("/events/stream", func(w http.ResponseWriter, r *http.Request) {
+		scanner := bufio.NewScanner(r.Body)
+
+		line := 0
+		for scanner.Scan() {
+			line++
+
+			var e map[string]interface{}
+			assert.Nil(t, json.Unmarshal(scanner.Bytes(), &e))
+
+			fmt.Fprintf(w, `{"line":%d,"status":200,"message":"%s[0]@%d","cluster":"%s"}`+"\n", line, e["topic"], line, e["cluster"])
+		}
+	})
+
+	mux.HandleFunc("/clusters", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"clusters":["kafka-cl01"]}`))
+	})
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("{\"errors\": \"cluster kafka-cl01 is unhealthy\n\"}"))
+	})
+
+	mux.HandleFunc("/clusters/kafka-cl01/topics/orders", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"orders","partitions":1,"replicationFactor":3,"underReplicatedPartitions":0,` +
+			`"partitionDetails":[{"id":0,"leader":1,"replicas":[1,2,3],"isr":[1,2]}],"configs":{"retention.ms":"604800000"}}`))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func runKppctl(t *testing.T, stdin string, args ...string) (int, string, string) {
+	srv := newTestProxy(t)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(append([]string{"-url", srv.URL, "-token", "TestApiToken"}, args...), strings.NewReader(stdin), stdout, stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestPublish(t *testing.T) {
+	tests := []struct {
+		name   string
+		stdin  string
+		args   []string
+		code   int
+		stdout []string
+	}{
+		{
+			name:   "arguments",
+			args:   []string{"publish", "-cluster", "kafka-cl01", "-topic", "orders", "-key", "1", `{"id":1}`},
+			stdout: []string{"EVENT", "published", "orders[0]@0"},
+		},
+		{
+			name:   "stdin",
+			stdin:  "{\"cluster\":\"kafka-cl01\",\"topic\":\"orders\",\"key\":1,\"data\":{}}\n\n{\"key\":2,\"data\":{}}\n",
+			args:   []string{"publish", "-topic", "payments", "-cluster", "kafka-cl02"},
+			stdout: []string{"orders[0]@1", "payments[0]@2", "kafka-cl02"},
+		},
+		{
+			name:   "failed",
+			args:   []string{"publish", "-cluster", "kafka-cl01", "-topic", "missing", `{"id":1}`},
+			code:   1,
+			stdout: []string{"topic_not_found", "topic missing does not exist"},
+		},
+		{
+			name: "invalid data",
+			args: []string{"publish", "-cluster", "kafka-cl01", "-topic", "orders", `[1]`},
+			code: 1,
+		},
+		{
+			name:  "unknown field",
+			stdin: "{\"cluster\":\"kafka-cl01\",\"topic\":\"orders\",\"payload\":{}}\n",
+			args:  []string{"publish"},
+			code:  1,
+		},
+		{
+			name: "arguments and file",
+			args: []string{"publish", "-f", "events.ndjson", `{"id":1}`},
+			code: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, _ := runKppctl(t, tt.stdin, tt.args...)
+
+			assert.Equal(t, tt.code, code)
+			for _, s := range tt.stdout {
+				assert.Contains(t, stdout, s)
+			}
+		})
+	}
+}
+
+func TestPublishJSON(t *testing.T) {
+	code, stdout, _ := runKppctl(t, "", "-o", "json", "publish", "-cluster", "kafka-cl01", "-topic", "missing", `{"id":1}`)
+
+	assert.Equal(t, 1, code)
+
+	var results []publishResult
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &results))
+	assert.Equal(t, []publishResult{{Event: 1, Status: "topic_not_found", Message: "topic missing does not exist", Code: "topic_not_found"}}, results)
+}
+
+func TestClusters(t *testing.T) {
+	code, stdout, _ := runKppctl(t, "", "-o", "json", "clusters")
+
+	assert.Equal(t, 0, code)
+	assert.JSONEq(t, `{"clusters":["kafka-cl01"]}`, stdout)
+}
+
+func TestHealth(t *testing.T) {
+	code, stdout, stderr := runKppctl(t, "", "health")
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "cluster kafka-cl01 is unhealthy")
+	assert.Contains(t, stderr, "proxy is unhealthy")
+}
+
+func TestTopicsDescribe(t *testing.T) {
+	code, stdout, _ := runKppctl(t, "", "topics", "describe", "kafka-cl01", "orders")
+
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "1,2,3")
+	assert.Contains(t, stdout, "retention.ms")
+
+	code, _, _ = runKppctl(t, "", "topics", "list")
+	assert.Equal(t, 2, code)
+}
+
+func TestValidateConfig(t *testing.T) {
+	code, stdout, _ := runKppctl(t, "", "validate-config", "../../testdata/app-config.json")
+
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "is valid")
+
+	code, _, stderr := runKppctl(t, "", "validate-config", "missing.json")
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "missing.json is invalid")
+
+	code, stdout, _ = runKppctl(t, "", "-o", "json", "validate-config", "-secrets", "../../testdata", "../../testdata/app-config.json")
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "internal-ca.json: certificate: expired")
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := runKppctl(t, "", "produce")
+
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "produce"`)
+
+	code, _, _ = runKppctl(t, "", "-o", "yaml", "clusters")
+	assert.Equal(t, 2, code)
+
+	// -key is the event key of publish, the mTLS key is -tls-key
+	code, _, stderr = runKppctl(t, "", "-key", "client.key", "clusters")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "-tls-key")
+}
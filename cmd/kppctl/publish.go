@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/traviisd/kafka-producer-proxy/api"
+	"github.com/traviisd/kafka-producer-proxy/client"
+)
+
+// maxEventBytes bounds a line of the NDJSON input, the proxy's maxBodyBytes defaults to 1MB.
+const maxEventBytes = 1 << 20
+
+// publishResult is written for every event, in the order of the events.
+type publishResult struct {
+	Event     int                 `json:"event"`
+	Status    string              `json:"status"`
+	Message   string              `json:"message,omitempty"`
+	Cluster   string              `json:"cluster,omitempty"`
+	Code      string              `json:"code,omitempty"`
+	Retriable bool                `json:"retriable,omitempty"`
+	Fields    []client.FieldError `json:"fields,omitempty"`
+}
+
+func (c *cli) publish(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+
+	cluster := fs.String("cluster", "", "cluster of the events without one")
+	topic := fs.String("topic", "", "topic of the events without one")
+	key := fs.String("key", "", "key of the events of the arguments")
+	file := fs.String("f", "", "NDJSON file of events, - for stdin")
+	idempotencyKey := fs.String("idempotency-key", "", "Idempotency-Key of a single event")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	var requests []api.EventRequest
+	var err error
+
+	switch {
+	case fs.NArg() > 0 && len(*file) > 0:
+		fmt.Fprintln(c.stderr, "publish takes either data arguments or -f")
+		return errUsage
+	case fs.NArg() > 0:
+		requests, err = eventsFromArgs(fs.Args(), *key)
+	case len(*file) > 0 && *file != "-":
+		f, ferr := os.Open(*file)
+		if ferr != nil {
+			return ferr
+		}
+		defer f.Close()
+
+		requests, err = readEvents(f)
+	default:
+		requests, err = readEvents(c.stdin)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(requests) == 0 {
+		return fmt.Errorf("no events to publish")
+	}
+
+	if len(*idempotencyKey) > 0 && len(requests) > 1 {
+		fmt.Fprintln(c.stderr, "-idempotency-key requires a single event")
+		return errUsage
+	}
+
+	events := make([]client.Event, len(requests))
+	for i, er := range requests {
+		if len(er.Cluster) == 0 {
+			er.Cluster = *cluster
+		}
+
+		if len(er.Topic) == 0 {
+			er.Topic = *topic
+		}
+
+		events[i] = newEvent(er)
+	}
+
+	events[0].IdempotencyKey = *idempotencyKey
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var batch []client.BatchResult
+
+	if len(events) == 1 {
+		result, err := cl.Publish(ctx, events[0])
+		if err != nil {
+			batch = []client.BatchResult{{Err: err}}
+		} else {
+			batch = []client.BatchResult{{PublishResult: *result}}
+		}
+	} else {
+		if batch, err = cl.PublishBatch(ctx, events); err != nil {
+			return err
+		}
+	}
+
+	results := make([]publishResult, len(batch))
+	failed := 0
+
+	for i, r := range batch {
+		results[i] = newPublishResult(i+1, r)
+
+		if r.Err != nil {
+			failed++
+		}
+	}
+
+	if err := c.print(results, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "EVENT\tSTATUS\tCLUSTER\tMESSAGE")
+		for _, r := range results {
+			msg := r.Message
+			for _, f := range r.Fields {
+				msg = fmt.Sprintf("%s; %s: %s", msg, f.Field, f.Message)
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Event, r.Status, r.Cluster, msg)
+		}
+	}); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d events failed", failed, len(results))
+	}
+
+	return nil
+}
+
+// eventsFromArgs returns an event per JSON data argument.
+func eventsFromArgs(args []string, key string) ([]api.EventRequest, error) {
+	requests := make([]api.EventRequest, len(args))
+
+	for i, arg := range args {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(arg), &data); err != nil {
+			return nil, fmt.Errorf("argument %d is not a JSON object: %w", i+1, err)
+		}
+
+		requests[i] = api.EventRequest{Data: data}
+
+		if len(key) > 0 {
+			requests[i].Key = key
+		}
+	}
+
+	return requests, nil
+}
+
+// readEvents decodes every line of the NDJSON input as an /events request, blank lines are skipped.
+// Unknown fields are rejected like the proxy does, so typos don't silently drop a field.
+func readEvents(r io.Reader) ([]api.EventRequest, error) {
+	requests := []api.EventRequest{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventBytes)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+
+		var er api.EventRequest
+		if err := dec.Decode(&er); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if dec.More() {
+			return nil, fmt.Errorf("line %d: must contain a single JSON object", line)
+		}
+
+		requests = append(requests, er)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading events: %w", err)
+	}
+
+	return requests, nil
+}
+
+func newEvent(er api.EventRequest) client.Event {
+	return client.Event{
+		Cluster:    er.Cluster,
+		Topic:      er.Topic,
+		Key:        er.Key,
+		Data:       er.Data,
+		Durability: er.Durability,
+	}
+}
+
+func newPublishResult(event int, r client.BatchResult) publishResult {
+	if r.Err == nil {
+		status := "published"
+		if r.Spooled {
+			status = "spooled"
+		}
+
+		return publishResult{Event: event, Status: status, Message: r.Message, Cluster: r.Cluster}
+	}
+
+	result := publishResult{Event: event, Status: "failed", Message: r.Err.Error()}
+
+	if e, ok := r.Err.(*client.Error); ok {
+		result.Message = e.Message
+		result.Retriable = e.Retriable
+		result.Code = e.Code
+		result.Fields = e.Fields
+
+		if len(e.Code) > 0 {
+			result.Status = e.Code
+		}
+	}
+
+	return result
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -14,7 +16,22 @@ func main() {
 	// UNIX Time is faster and smaller than most timestamps
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
-	api.SetAppConfig(os.Getenv("KAFKA_PRODUCER_PROXY_APP_CONFIG"))
+	appConfig := os.Getenv("KAFKA_PRODUCER_PROXY_APP_CONFIG")
+
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:], appConfig, os.Getenv("KAFKA_PRODUCER_PROXY_SECRETS_PATH")))
+	}
+
+	if errs := api.CheckAppConfig(appConfig); len(errs) > 0 {
+		for _, err := range errs {
+			log.Error().Err(err).Msg("invalid app-config.json")
+		}
+		log.Fatal().Msg("invalid app-config.json, run with validate to check the secrets too")
+	}
+
+	if err := api.SetAppConfig(appConfig); err != nil {
+		log.Fatal().Err(err).Msg("error reading app-config.json")
+	}
 
 	done := make(chan bool)
 	defer func() {
@@ -22,12 +39,36 @@ func main() {
 	}()
 
 	go configureSecrets(done)
+	go configureAppConfig(done)
 	// need to warmup so secrets get added.
 	time.Sleep(time.Second * 5)
 
 	api.Serve()
 }
 
+// validate prints every problem of the app-config.json, secrets.json and internal-ca.json at once,
+// to check a configuration before deploying it. Exits non-zero if there are any.
+func validate(args []string, appConfig, secretsPath string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.StringVar(&appConfig, "app-config", appConfig, "app-config.json file, $KAFKA_PRODUCER_PROXY_APP_CONFIG")
+	fs.StringVar(&secretsPath, "secrets", secretsPath, "path of secrets.json and internal-ca.json, $KAFKA_PRODUCER_PROXY_SECRETS_PATH")
+	fs.Parse(args)
+
+	errs := api.ValidateConfig(appConfig, secretsPath)
+	if len(errs) == 0 {
+		fmt.Println("configuration is valid")
+		return 0
+	}
+
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "problems found: %d\n", len(errs))
+
+	return 1
+}
+
 func configureSecrets(done chan bool) {
 	secretsPath := os.Getenv("KAFKA_PRODUCER_PROXY_SECRETS_PATH")
 	sf := fmt.Sprintf("%s/secrets.json", secretsPath)
@@ -64,3 +105,19 @@ func configureSecrets(done chan bool) {
 
 	fpw.Watch(done)
 }
+
+// configureAppConfig watches the app-config.json for the settings that can be changed without a restart.
+func configureAppConfig(done chan bool) {
+	file := os.Getenv("KAFKA_PRODUCER_PROXY_APP_CONFIG")
+
+	fpw := api.NewFilePathWatcher(filepath.Dir(file), []api.DynamicFile{
+		{
+			File:       filepath.Base(file),
+			UpdateFunc: api.SetRateLimits,
+		},
+	})
+
+	if err := fpw.Watch(done); err != nil {
+		log.Err(err).Msg("error watching app-config.json, changes require a restart")
+	}
+}
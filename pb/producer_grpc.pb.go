@@ -0,0 +1,216 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ProducerClient is the client API for Producer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ProducerClient interface {
+	// Publish produces a single event.
+	Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*PublishResponse, error)
+	// PublishBatch produces the events in order, returning a result per event.
+	PublishBatch(ctx context.Context, in *PublishBatchRequest, opts ...grpc.CallOption) (*PublishBatchResponse, error)
+	// PublishStream produces the events as they are received, returning a result per event once
+	// the client closes the stream.
+	PublishStream(ctx context.Context, opts ...grpc.CallOption) (Producer_PublishStreamClient, error)
+}
+
+type producerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProducerClient(cc grpc.ClientConnInterface) ProducerClient {
+	return &producerClient{cc}
+}
+
+func (c *producerClient) Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*PublishResponse, error) {
+	out := new(PublishResponse)
+	err := c.cc.Invoke(ctx, "/kafkaproducerproxy.v1.Producer/Publish", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *producerClient) PublishBatch(ctx context.Context, in *PublishBatchRequest, opts ...grpc.CallOption) (*PublishBatchResponse, error) {
+	out := new(PublishBatchResponse)
+	err := c.cc.Invoke(ctx, "/kafkaproducerproxy.v1.Producer/PublishBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *producerClient) PublishStream(ctx context.Context, opts ...grpc.CallOption) (Producer_PublishStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Producer_ServiceDesc.Streams[0], "/kafkaproducerproxy.v1.Producer/PublishStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &producerPublishStreamClient{stream}
+	return x, nil
+}
+
+type Producer_PublishStreamClient interface {
+	Send(*PublishRequest) error
+	CloseAndRecv() (*PublishBatchResponse, error)
+	grpc.ClientStream
+}
+
+type producerPublishStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *producerPublishStreamClient) Send(m *PublishRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *producerPublishStreamClient) CloseAndRecv() (*PublishBatchResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(PublishBatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ProducerServer is the server API for Producer service.
+// All implementations must embed UnimplementedProducerServer
+// for forward compatibility
+type ProducerServer interface {
+	// Publish produces a single event.
+	Publish(context.Context, *PublishRequest) (*PublishResponse, error)
+	// PublishBatch produces the events in order, returning a result per event.
+	PublishBatch(context.Context, *PublishBatchRequest) (*PublishBatchResponse, error)
+	// PublishStream produces the events as they are received, returning a result per event once
+	// the client closes the stream.
+	PublishStream(Producer_PublishStreamServer) error
+	mustEmbedUnimplementedProducerServer()
+}
+
+// UnimplementedProducerServer must be embedded to have forward compatible implementations.
+type UnimplementedProducerServer struct {
+}
+
+func (UnimplementedProducerServer) Publish(context.Context, *PublishRequest) (*PublishResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Publish not implemented")
+}
+func (UnimplementedProducerServer) PublishBatch(context.Context, *PublishBatchRequest) (*PublishBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishBatch not implemented")
+}
+func (UnimplementedProducerServer) PublishStream(Producer_PublishStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method PublishStream not implemented")
+}
+func (UnimplementedProducerServer) mustEmbedUnimplementedProducerServer() {}
+
+// UnsafeProducerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProducerServer will
+// result in compilation errors.
+type UnsafeProducerServer interface {
+	mustEmbedUnimplementedProducerServer()
+}
+
+func RegisterProducerServer(s grpc.ServiceRegistrar, srv ProducerServer) {
+	s.RegisterService(&Producer_ServiceDesc, srv)
+}
+
+func _Producer_Publish_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublishRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProducerServer).Publish(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kafkaproducerproxy.v1.Producer/Publish",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProducerServer).Publish(ctx, req.(*PublishRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Producer_PublishBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublishBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProducerServer).PublishBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kafkaproducerproxy.v1.Producer/PublishBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProducerServer).PublishBatch(ctx, req.(*PublishBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Producer_PublishStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ProducerServer).PublishStream(&producerPublishStreamServer{stream})
+}
+
+type Producer_PublishStreamServer interface {
+	SendAndClose(*PublishBatchResponse) error
+	Recv() (*PublishRequest, error)
+	grpc.ServerStream
+}
+
+type producerPublishStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *producerPublishStreamServer) SendAndClose(m *PublishBatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *producerPublishStreamServer) Recv() (*PublishRequest, error) {
+	m := new(PublishRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Producer_ServiceDesc is the grpc.ServiceDesc for Producer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Producer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kafkaproducerproxy.v1.Producer",
+	HandlerType: (*ProducerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Publish",
+			Handler:    _Producer_Publish_Handler,
+		},
+		{
+			MethodName: "PublishBatch",
+			Handler:    _Producer_PublishBatch_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PublishStream",
+			Handler:       _Producer_PublishStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "producer.proto",
+}